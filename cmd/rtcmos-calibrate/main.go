@@ -0,0 +1,137 @@
+// Command rtcmos-calibrate fits the scoring model coefficients to subjective ratings.
+//
+// Usage:
+//
+//	rtcmos-calibrate -data ratings.csv [-media audio|video|all] [-folds 5] [-coefficients initial.yaml] [-out fitted.yaml]
+//
+// The dataset is CSV with a header row or JSON lines of {"stat": ..., "mos": ...}.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/livekit/rtcscore-go/pkg/calibrate"
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+func main() {
+	data := flag.String("data", "", "labeled dataset, .csv or .jsonl")
+	media := flag.String("media", "all", "coefficients to fit: audio, video or all")
+	folds := flag.Int("folds", 0, "number of folds of the cross validation, none when 0")
+	initialPath := flag.String("coefficients", "", "initial coefficients file, built-in coefficients when empty")
+	out := flag.String("out", "", "fitted coefficients file, .json or .yaml, printed as JSON when empty")
+	flag.Parse()
+
+	if err := run(*data, *media, *folds, *initialPath, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "rtcmos-calibrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(data, media string, folds int, initialPath, out string) error {
+	if data == "" {
+		return fmt.Errorf("-data is required")
+	}
+	if media != "audio" && media != "video" && media != "all" {
+		return fmt.Errorf("-media must be audio, video or all")
+	}
+
+	samples, err := calibrate.ReadSamples(data)
+	if err != nil {
+		return err
+	}
+
+	initial := rtcmos.DefaultCoefficients()
+	if initialPath != "" {
+		if initial, err = rtcmos.LoadCoefficients(initialPath); err != nil {
+			return err
+		}
+	}
+
+	audioSamples, videoSamples := split(samples)
+	coefficients := initial
+	if media == "audio" || (media == "all" && len(audioSamples) > 0) {
+		fit, err := calibrate.FitAudio(audioSamples, coefficients)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "audio: fitted %v\n", fit.Fitted)
+		for _, configuration := range fit.Configurations {
+			fmt.Fprintf(os.Stderr, "  fec=%-5v dtx=%-5v red=%-5v bandwidth=%-10s samples=%d fitted=%-5v ie=%.2f bpl=%.2f\n",
+				configuration.Fec, configuration.Dtx, configuration.Red, configuration.Bandwidth, configuration.Samples,
+				configuration.Fitted, configuration.Ie, configuration.Bpl)
+		}
+		printMetrics("before", fit.Before)
+		printMetrics("after", fit.After)
+		coefficients = fit.Coefficients
+
+		if err = crossValidate(audioSamples, folds, func(samples []calibrate.Sample) (*rtcmos.Coefficients, error) {
+			fit, err := calibrate.FitAudio(samples, initial)
+			if err != nil {
+				return nil, err
+			}
+			return fit.Coefficients, nil
+		}); err != nil {
+			return err
+		}
+	}
+	if media == "video" || (media == "all" && len(videoSamples) > 0) {
+		fit, err := calibrate.FitVideo(videoSamples, coefficients)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "video: fitted %v\n", fit.Fitted)
+		printMetrics("before", fit.Before)
+		printMetrics("after", fit.After)
+		coefficients = fit.Coefficients
+
+		if err = crossValidate(videoSamples, folds, func(samples []calibrate.Sample) (*rtcmos.Coefficients, error) {
+			fit, err := calibrate.FitVideo(samples, initial)
+			if err != nil {
+				return nil, err
+			}
+			return fit.Coefficients, nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	if out != "" {
+		return rtcmos.SaveCoefficients(out, coefficients)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(coefficients)
+}
+
+// crossValidate - prints the k-fold cross validation metrics of the fitter, nothing when folds is 0
+func crossValidate(samples []calibrate.Sample, folds int, fit calibrate.Fitter) error {
+	if folds == 0 {
+		return nil
+	}
+	metrics, err := calibrate.CrossValidate(samples, folds, fit)
+	if err != nil {
+		return fmt.Errorf("cross validation: %w", err)
+	}
+	printMetrics(fmt.Sprintf("%d-fold", folds), metrics)
+	return nil
+}
+
+func split(samples []calibrate.Sample) (audio []calibrate.Sample, video []calibrate.Sample) {
+	for _, sample := range samples {
+		if sample.Stat.AudioConfig != nil {
+			audio = append(audio, sample)
+		} else {
+			video = append(video, sample)
+		}
+	}
+	return audio, video
+}
+
+func printMetrics(label string, metrics calibrate.Metrics) {
+	fmt.Fprintf(os.Stderr, "  %-6s samples=%d rmse=%.3f pearson=%.3f spearman=%.3f\n",
+		label, metrics.Samples, metrics.RMSE, metrics.Pearson, metrics.Spearman)
+}
@@ -0,0 +1,400 @@
+package calibrate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+// AudioFit is the result of FitAudio
+type AudioFit struct {
+	// Coefficients: initial coefficients with the fitted Ie and Bpl, and the fitted configurations in
+	// Audio.Configurations
+	Coefficients *rtcmos.Coefficients
+	// Fitted: names of the fitted coefficients, the others keep their initial value
+	Fitted []string
+	// Configurations: impairments of each opus configuration found in the samples
+	Configurations []AudioConfiguration
+	// Before, After: goodness of fit on the audio samples with the initial and the fitted coefficients
+	Before Metrics
+	After  Metrics
+}
+
+// MinConfigurationSamples: samples needed to fit the impairments of a configuration on its own samples
+const MinConfigurationSamples = 10
+
+// AudioConfiguration contains the impairments of a combination of opus flags and bandwidth
+type AudioConfiguration struct {
+	Fec       bool
+	Dtx       bool
+	Red       bool
+	Bandwidth rtcmos.AudioBandwidth
+	Samples   int
+	// Fitted: Ie and Bpl are fitted on the samples of the configuration, with the slope of the Ie curve of the
+	// global fit, and stored in the Audio.Configurations of the fitted coefficients. With less than
+	// MinConfigurationSamples samples they are derived from the global fit instead.
+	Fitted bool
+	// Ie: equipment impairment factor at the median bitrate of the samples (narrowband scale)
+	Ie float64
+	// Bpl: packet loss robustness factor, the global value when the samples have no packet loss
+	Bpl float64
+}
+
+// audioFlags - opus flags and bandwidth of a sample with the defaults of AudioScore
+type audioFlags struct {
+	fec, dtx, red bool
+	bandwidth     rtcmos.AudioBandwidth
+}
+
+func flagsOf(audioConfig *rtcmos.AudioConfig) audioFlags {
+	flags := audioFlags{
+		fec:       audioConfig.Fec == nil || *audioConfig.Fec,
+		dtx:       audioConfig.Dtx != nil && *audioConfig.Dtx,
+		red:       audioConfig.Red != nil && *audioConfig.Red,
+		bandwidth: audioConfig.Bandwidth,
+	}
+	if flags.bandwidth == "" {
+		flags.bandwidth = rtcmos.AudioBandwidthNarrowband
+	}
+	return flags
+}
+
+func isOpus(audioConfig *rtcmos.AudioConfig) bool {
+	codec := strings.ToLower(string(audioConfig.Codec))
+	return codec == "" || codec == string(rtcmos.AudioCodecOpus)
+}
+
+// audioParameter - a coefficient of the audio model and the samples which inform it
+type audioParameter struct {
+	name  string
+	value func(c *rtcmos.AudioCoefficients) *float64
+	// informs: the sample depends on the coefficient
+	informs func(sample Sample, flags audioFlags) bool
+	// positive parameters are optimized on a log scale, others on a linear one with the given scale
+	positive bool
+	scale    float64
+}
+
+var audioParameters = []audioParameter{
+	{
+		name:  "Audio.IeIntercept",
+		value: func(c *rtcmos.AudioCoefficients) *float64 { return &c.IeIntercept },
+		informs: func(sample Sample, flags audioFlags) bool {
+			return isOpus(sample.Stat.AudioConfig) && !flags.dtx && sample.Stat.Bitrate > 0
+		},
+		scale: 10,
+	},
+	{
+		name:  "Audio.IeSlope",
+		value: func(c *rtcmos.AudioCoefficients) *float64 { return &c.IeSlope },
+		informs: func(sample Sample, flags audioFlags) bool {
+			return isOpus(sample.Stat.AudioConfig) && !flags.dtx && sample.Stat.Bitrate > 0
+		},
+		scale: 1,
+	},
+	{
+		name:  "Audio.IeDtx",
+		value: func(c *rtcmos.AudioCoefficients) *float64 { return &c.IeDtx },
+		informs: func(sample Sample, flags audioFlags) bool {
+			return isOpus(sample.Stat.AudioConfig) && flags.dtx
+		},
+		scale: 5,
+	},
+	{
+		name:  "Audio.IeUnknownBitrate",
+		value: func(c *rtcmos.AudioCoefficients) *float64 { return &c.IeUnknownBitrate },
+		informs: func(sample Sample, flags audioFlags) bool {
+			return isOpus(sample.Stat.AudioConfig) && !flags.dtx && sample.Stat.Bitrate == 0
+		},
+		scale: 5,
+	},
+	{
+		name:  "Audio.Bpl",
+		value: func(c *rtcmos.AudioCoefficients) *float64 { return &c.Bpl },
+		informs: func(sample Sample, flags audioFlags) bool {
+			return isOpus(sample.Stat.AudioConfig) && !flags.fec && !flags.red && sample.Stat.PacketLoss > 0
+		},
+		positive: true,
+	},
+	{
+		name:  "Audio.BplFec",
+		value: func(c *rtcmos.AudioCoefficients) *float64 { return &c.BplFec },
+		informs: func(sample Sample, flags audioFlags) bool {
+			return isOpus(sample.Stat.AudioConfig) && flags.fec && !flags.red && sample.Stat.PacketLoss > 0
+		},
+		positive: true,
+	},
+	{
+		name:  "Audio.BplRed",
+		value: func(c *rtcmos.AudioCoefficients) *float64 { return &c.BplRed },
+		informs: func(sample Sample, flags audioFlags) bool {
+			return flags.red && sample.Stat.PacketLoss > 0
+		},
+		positive: true,
+	},
+}
+
+func (p audioParameter) toX(value float64) float64 {
+	if p.positive {
+		return math.Log(value)
+	}
+	return value / p.scale
+}
+
+func (p audioParameter) fromX(x float64) float64 {
+	if p.positive {
+		return math.Exp(x)
+	}
+	return x * p.scale
+}
+
+// FitAudio estimates the Ie and Bpl coefficients of the audio model from the audio samples, starting from the
+// initial coefficients (DefaultCoefficients when nil). The squared error of AudioScore against the subjective
+// scores is minimized, so the fitted coefficients plug straight back into audio scoring.
+//
+// Only the coefficients informed by the samples are fitted, e.g. BplRed needs samples with RED and packet loss.
+// The configurations of the initial Audio.Configurations found in the samples are replaced by the fitted ones,
+// the others are kept.
+func FitAudio(samples []Sample, initial *rtcmos.Coefficients) (*AudioFit, error) {
+	if initial == nil {
+		initial = rtcmos.DefaultCoefficients()
+	}
+	samples = audioSamples(samples)
+
+	var parameters []audioParameter
+	for _, parameter := range audioParameters {
+		var informing []float64
+		for _, sample := range samples {
+			if parameter.informs(sample, flagsOf(sample.Stat.AudioConfig)) {
+				informing = append(informing, math.Log(float64(sample.Stat.Bitrate)+1))
+			}
+		}
+		// the slope needs several bitrates, the others at least a sample
+		if len(informing) == 0 || (parameter.name == "Audio.IeSlope" && variance(informing) < 1e-6) {
+			continue
+		}
+		parameters = append(parameters, parameter)
+	}
+	if len(parameters) == 0 {
+		return nil, fmt.Errorf("%w: no audio samples inform the audio coefficients", ErrSingular)
+	}
+	if len(samples) <= len(parameters) {
+		return nil, fmt.Errorf("%w: %d audio samples for %d coefficients", ErrSingular, len(samples), len(parameters))
+	}
+
+	// the global coefficients are fitted without the configurations, which would hide them
+	global := initial.Clone()
+	global.Audio.Configurations = nil
+	coefficients := fitAudioParameters(samples, global, parameters)
+	configurations, fitted := audioConfigurations(samples, coefficients, parameters)
+	coefficients.Audio.Configurations = mergeConfigurations(initial.Audio.Configurations, configurations, fitted)
+
+	fit := &AudioFit{
+		Coefficients:   coefficients,
+		Configurations: configurations,
+		Before:         Measure(samples, initial),
+		After:          Measure(samples, coefficients),
+	}
+	for _, parameter := range parameters {
+		fit.Fitted = append(fit.Fitted, parameter.name)
+	}
+	return fit, nil
+}
+
+// fitAudioParameters - minimizes the squared error of AudioScore on the samples over the parameters
+func fitAudioParameters(samples []Sample, initial *rtcmos.Coefficients, parameters []audioParameter) *rtcmos.Coefficients {
+	coefficients := initial.Clone()
+	apply := func(x []float64) {
+		for i, parameter := range parameters {
+			*parameter.value(&coefficients.Audio) = parameter.fromX(x[i])
+		}
+	}
+	cost := func(x []float64) float64 {
+		apply(x)
+		if coefficients.Validate() != nil {
+			return math.Inf(1)
+		}
+		predicted, observed := predict(samples, coefficients)
+		var squares float64
+		for i := range predicted {
+			squares += (predicted[i] - observed[i]) * (predicted[i] - observed[i])
+		}
+		return squares
+	}
+
+	x := make([]float64, len(parameters))
+	for i, parameter := range parameters {
+		x[i] = parameter.toX(*parameter.value(&initial.Audio))
+	}
+	// restarting from the best vertex helps the simplex out of the plateaus of rounded scores
+	for _, step := range []float64{1, 0.5, 0.2} {
+		x = nelderMead(cost, x, step, 400)
+	}
+	apply(x)
+	return coefficients
+}
+
+// audioConfigurations - impairments of each combination of opus flags and bandwidth found in the samples, and the
+// coefficients of the fitted ones.
+//
+// Configurations with at least MinConfigurationSamples samples get their own Ie and Bpl, fitted on their samples
+// from the global fit. The slope of the Ie curve is kept from the global fit as a configuration rarely has enough
+// bitrates to estimate it.
+func audioConfigurations(samples []Sample, coefficients *rtcmos.Coefficients,
+	parameters []audioParameter) ([]AudioConfiguration, []rtcmos.AudioConfigurationCoefficients) {
+	groups := map[audioFlags][]Sample{}
+	for _, sample := range samples {
+		if isOpus(sample.Stat.AudioConfig) {
+			flags := flagsOf(sample.Stat.AudioConfig)
+			groups[flags] = append(groups[flags], sample)
+		}
+	}
+
+	var configurations []AudioConfiguration
+	var fittedConfigurations []rtcmos.AudioConfigurationCoefficients
+	for flags, group := range groups {
+		configuration := AudioConfiguration{
+			Fec:       flags.fec,
+			Dtx:       flags.dtx,
+			Red:       flags.red,
+			Bandwidth: flags.bandwidth,
+			Samples:   len(group),
+		}
+
+		fitted := coefficients
+		var informed []audioParameter
+		for _, parameter := range parameters {
+			if parameter.name != "Audio.IeSlope" && informsAny(parameter, group) {
+				informed = append(informed, parameter)
+			}
+		}
+		if len(group) >= MinConfigurationSamples && len(informed) > 0 && len(group) > len(informed) {
+			fitted = fitAudioParameters(group, coefficients, informed)
+			configuration.Fitted = true
+		}
+
+		bitrates := make([]float64, 0, len(group))
+		for _, sample := range group {
+			bitrates = append(bitrates, float64(sample.Stat.Bitrate))
+		}
+		sort.Float64s(bitrates)
+		configuration.Ie, configuration.Bpl = audioImpairments(flags, bitrates[len(bitrates)/2], &fitted.Audio)
+		configurations = append(configurations, configuration)
+		if configuration.Fitted {
+			_, Bpl := audioImpairments(flags, 0, &fitted.Audio)
+			fittedConfigurations = append(fittedConfigurations, rtcmos.AudioConfigurationCoefficients{
+				Fec:              flags.fec,
+				Dtx:              flags.dtx,
+				Red:              flags.red,
+				Bandwidth:        flags.bandwidth,
+				IeIntercept:      fitted.Audio.IeIntercept,
+				IeDtx:            fitted.Audio.IeDtx,
+				IeUnknownBitrate: fitted.Audio.IeUnknownBitrate,
+				Bpl:              Bpl,
+			})
+		}
+	}
+
+	sort.Slice(configurations, func(i, j int) bool {
+		return configurationLess(configurations[i].flags(), configurations[j].flags())
+	})
+	sort.Slice(fittedConfigurations, func(i, j int) bool {
+		a, b := fittedConfigurations[i], fittedConfigurations[j]
+		return configurationLess(configurationFlags(a), configurationFlags(b))
+	})
+	return configurations, fittedConfigurations
+}
+
+// configurationLess - orders configurations by fec, dtx, red and bandwidth
+func configurationLess(a, b audioFlags) bool {
+	if a.fec != b.fec {
+		return !a.fec
+	}
+	if a.dtx != b.dtx {
+		return !a.dtx
+	}
+	if a.red != b.red {
+		return !a.red
+	}
+	return a.bandwidth.Scale() < b.bandwidth.Scale()
+}
+
+// flags - flags and bandwidth of the configuration
+func (c AudioConfiguration) flags() audioFlags {
+	return audioFlags{fec: c.Fec, dtx: c.Dtx, red: c.Red, bandwidth: c.Bandwidth}
+}
+
+// configurationFlags - flags and bandwidth of configuration coefficients, the empty bandwidth being narrowband
+func configurationFlags(c rtcmos.AudioConfigurationCoefficients) audioFlags {
+	flags := audioFlags{fec: c.Fec, dtx: c.Dtx, red: c.Red, bandwidth: c.Bandwidth}
+	if flags.bandwidth == "" {
+		flags.bandwidth = rtcmos.AudioBandwidthNarrowband
+	}
+	return flags
+}
+
+// mergeConfigurations - fitted configurations and the initial ones of configurations missing from the samples
+func mergeConfigurations(initial []rtcmos.AudioConfigurationCoefficients, configurations []AudioConfiguration,
+	fitted []rtcmos.AudioConfigurationCoefficients) []rtcmos.AudioConfigurationCoefficients {
+	sampled := map[audioFlags]bool{}
+	for _, configuration := range configurations {
+		sampled[configuration.flags()] = true
+	}
+
+	var merged []rtcmos.AudioConfigurationCoefficients
+	for _, configuration := range initial {
+		if !sampled[configurationFlags(configuration)] {
+			merged = append(merged, configuration)
+		}
+	}
+	merged = append(merged, fitted...)
+	sort.Slice(merged, func(i, j int) bool {
+		return configurationLess(configurationFlags(merged[i]), configurationFlags(merged[j]))
+	})
+	return merged
+}
+
+// informsAny - one of the samples depends on the parameter
+func informsAny(parameter audioParameter, samples []Sample) bool {
+	for _, sample := range samples {
+		if parameter.informs(sample, flagsOf(sample.Stat.AudioConfig)) {
+			return true
+		}
+	}
+	return false
+}
+
+// audioImpairments - Ie (narrowband scale) at the bitrate and Bpl of the flags
+func audioImpairments(flags audioFlags, bitrate float64, c *rtcmos.AudioCoefficients) (float64, float64) {
+	var Ie, Bpl float64
+	switch {
+	case flags.dtx:
+		Ie = c.IeDtx
+	case bitrate > 0:
+		Ie = math.Max(0, math.Min(c.IeIntercept-c.IeSlope*math.Log(bitrate), c.IeMax))
+	default:
+		Ie = c.IeUnknownBitrate
+	}
+	switch {
+	case flags.red:
+		Bpl = c.BplRed
+	case flags.fec:
+		Bpl = c.BplFec
+	default:
+		Bpl = c.Bpl
+	}
+	return Ie, Bpl
+}
+
+func audioSamples(samples []Sample) []Sample {
+	var result []Sample
+	for _, sample := range samples {
+		if sample.Stat.AudioConfig != nil {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
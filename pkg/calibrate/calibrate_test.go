@@ -0,0 +1,346 @@
+package calibrate
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+func int32Ptr(x int32) *int32 {
+	return &x
+}
+
+func float32Ptr(x float32) *float32 {
+	return &x
+}
+
+// syntheticVideoSamples - samples rated exactly by the model with the given coefficients
+func syntheticVideoSamples(coefficients *rtcmos.Coefficients) []Sample {
+	var samples []Sample
+	for _, bitrate := range []float32{150000, 300000, 600000, 1200000} {
+		for _, frameRate := range []float32{10, 20, 30} {
+			for _, rtt := range []int32{20, 200, 400} {
+				stat := rtcmos.Stat{
+					Bitrate:       bitrate,
+					RoundTripTime: int32Ptr(rtt),
+					BufferDelay:   int32Ptr(0),
+					VideoConfig: &rtcmos.VideoConfig{
+						Width:             int32Ptr(1280),
+						Height:            int32Ptr(720),
+						FrameRate:         float32Ptr(frameRate),
+						ExpectedFrameRate: float32Ptr(30),
+					},
+				}
+				samples = append(samples, Sample{Stat: stat, MOS: rtcmos.VideoScoreWith(stat, coefficients).VideoScore})
+			}
+		}
+	}
+	return samples
+}
+
+func TestFitVideo(t *testing.T) {
+	{
+		// coefficients used to rate the samples are recovered
+		target := rtcmos.DefaultCoefficients()
+		target.Video.BPPPFSlope = 0.5
+		target.Video.BPPPFIntercept = 5.6
+		target.Video.FrameRateFactor = 1.2
+		target.Video.DelayFactor = 0.003
+
+		initial := rtcmos.DefaultCoefficients()
+		fit, err := FitVideo(syntheticVideoSamples(target), initial)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Video.BPPPFSlope", "Video.BPPPFIntercept", "Video.FrameRateFactor", "Video.DelayFactor"}, fit.Fitted)
+		t.Log("before", fit.Before, "after", fit.After)
+		require.InDelta(t, 0.5, fit.Coefficients.Video.BPPPFSlope, 0.05)
+		require.InDelta(t, 5.6, fit.Coefficients.Video.BPPPFIntercept, 0.3)
+		require.InDelta(t, 1.2, fit.Coefficients.Video.FrameRateFactor, 0.1)
+		require.InDelta(t, 0.003, fit.Coefficients.Video.DelayFactor, 0.0005)
+		require.Less(t, fit.After.RMSE, fit.Before.RMSE)
+		require.Greater(t, fit.After.Pearson, 0.95)
+
+		// the initial coefficients are left untouched
+		require.Equal(t, rtcmos.DefaultCoefficients(), initial)
+	}
+	{
+		// terms the samples do not vary along keep their initial value
+		var samples []Sample
+		for _, sample := range syntheticVideoSamples(rtcmos.DefaultCoefficients()) {
+			if *sample.Stat.VideoConfig.FrameRate == 30 && *sample.Stat.RoundTripTime == 20 {
+				samples = append(samples, sample)
+			}
+		}
+		fit, err := FitVideo(samples, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Video.BPPPFSlope", "Video.BPPPFIntercept"}, fit.Fitted)
+		require.Equal(t, 1.9, fit.Coefficients.Video.FrameRateFactor)
+	}
+	{
+		// not enough samples
+		_, err := FitVideo(syntheticVideoSamples(rtcmos.DefaultCoefficients())[:2], nil)
+		require.ErrorIs(t, err, ErrSingular)
+	}
+}
+
+func TestReadSamples(t *testing.T) {
+	{
+		// CSV with a header row, empty cells keep the defaults
+		samples, err := ReadCSV(strings.NewReader("bitrate,width,height,frame_rate,round_trip_time,mos\n" +
+			"1700000,1280,720,30,50,4.2\n" +
+			"300000,640,360,,,3.1\n"))
+		require.NoError(t, err)
+		require.Len(t, samples, 2)
+		require.Equal(t, float32(1700000), samples[0].Stat.Bitrate)
+		require.Equal(t, int32(720), *samples[0].Stat.VideoConfig.Height)
+		require.Equal(t, 4.2, samples[0].MOS)
+		require.Nil(t, samples[1].Stat.VideoConfig.FrameRate)
+		require.Nil(t, samples[1].Stat.RoundTripTime)
+	}
+	{
+		// CSV errors
+		_, err := ReadCSV(strings.NewReader("bitrate,unknown,mos\n1,2,3\n"))
+		require.Error(t, err)
+		_, err = ReadCSV(strings.NewReader("bitrate,width\n1,2\n"))
+		require.Error(t, err)
+		_, err = ReadCSV(strings.NewReader("bitrate,width,mos\n100000,640,7\n"))
+		require.Error(t, err)
+		// non finite values would poison the fits
+		_, err = ReadCSV(strings.NewReader("mos,bitrate\nNaN,32000\n"))
+		require.Error(t, err)
+		_, err = ReadCSV(strings.NewReader("mos,bitrate,frame_rate\n3,100000,+Inf\n"))
+		require.Error(t, err)
+		require.Error(t, validateSample(Sample{MOS: math.NaN(), Stat: rtcmos.Stat{AudioConfig: &rtcmos.AudioConfig{}}}))
+	}
+	{
+		// audio rows without video columns, or with an explicit kind
+		samples, err := ReadCSV(strings.NewReader("kind,bitrate,packet_loss,codec,fec,dtx,red,width,mos\n" +
+			"audio,32000,2,opus,false,true,,,3.9\n" +
+			"video,500000,1,vp9,true,,,640,3.2\n"))
+		require.NoError(t, err)
+		require.Len(t, samples, 2)
+		require.Equal(t, rtcmos.AudioCodecOpus, samples[0].Stat.AudioConfig.Codec)
+		require.False(t, *samples[0].Stat.AudioConfig.Fec)
+		require.True(t, *samples[0].Stat.AudioConfig.Dtx)
+		require.Nil(t, samples[0].Stat.AudioConfig.Red)
+		require.Nil(t, samples[0].Stat.VideoConfig)
+		require.Equal(t, "vp9", samples[1].Stat.VideoConfig.Codec)
+		require.True(t, *samples[1].Stat.VideoConfig.Fec)
+
+		samples, err = ReadCSV(strings.NewReader("bitrate,packet_loss,mos\n24000,3,3.7\n"))
+		require.NoError(t, err)
+		require.NotNil(t, samples[0].Stat.AudioConfig)
+	}
+	{
+		// JSON lines
+		samples, err := ReadJSONL(strings.NewReader(`{"stat": {"Bitrate": 500000, "VideoConfig": {"Width": 640, "Height": 360}}, "mos": 3.5}` + "\n\n" +
+			`{"stat": {"PacketLoss": 2, "AudioConfig": {}}, "mos": 4}` + "\n"))
+		require.NoError(t, err)
+		require.Len(t, samples, 2)
+		require.Equal(t, int32(640), *samples[0].Stat.VideoConfig.Width)
+		require.NotNil(t, samples[1].Stat.AudioConfig)
+
+		_, err = ReadJSONL(strings.NewReader(`{"stat": {}, "mos": 3}`))
+		require.Error(t, err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	{
+		// perfect prediction
+		metrics := ComputeMetrics([]float64{1, 2, 3}, []float64{1, 2, 3})
+		require.Equal(t, 3, metrics.Samples)
+		require.Equal(t, 0.0, metrics.RMSE)
+		require.InDelta(t, 1.0, metrics.Pearson, 1e-9)
+		require.InDelta(t, 1.0, metrics.Spearman, 1e-9)
+	}
+	{
+		// monotonic but not linear, with ties
+		metrics := ComputeMetrics([]float64{1, 2, 2, 10}, []float64{1, 3, 3, 4})
+		require.InDelta(t, 1.0, metrics.Spearman, 1e-9)
+		require.Less(t, metrics.Pearson, 1.0)
+		require.Greater(t, metrics.RMSE, 0.0)
+	}
+}
+
+func boolPtr(x bool) *bool {
+	return &x
+}
+
+// syntheticAudioSamples - samples rated exactly by the model with the given coefficients
+func syntheticAudioSamples(coefficients *rtcmos.Coefficients) []Sample {
+	var samples []Sample
+	for _, flags := range []struct{ fec, dtx, red bool }{{false, false, false}, {true, false, false}, {true, true, false}, {false, false, true}} {
+		for _, bitrate := range []float32{8000, 16000, 32000, 64000} {
+			for _, loss := range []float32{0, 1, 3, 6, 10, 20} {
+				stat := rtcmos.Stat{
+					Bitrate:       bitrate,
+					PacketLoss:    loss,
+					RoundTripTime: int32Ptr(100),
+					BufferDelay:   int32Ptr(40),
+					AudioConfig:   &rtcmos.AudioConfig{Fec: boolPtr(flags.fec), Dtx: boolPtr(flags.dtx), Red: boolPtr(flags.red)},
+				}
+				samples = append(samples, Sample{Stat: stat, MOS: rtcmos.AudioScoreWith(stat, coefficients).AudioScore})
+			}
+		}
+	}
+	return samples
+}
+
+func TestFitAudio(t *testing.T) {
+	target := rtcmos.DefaultCoefficients()
+	target.Audio.IeIntercept = 60
+	target.Audio.IeSlope = 5
+	target.Audio.IeDtx = 12
+	target.Audio.Bpl = 6
+	target.Audio.BplFec = 15
+	target.Audio.BplRed = 50
+	{
+		// coefficients used to rate the samples are recovered
+		initial := rtcmos.DefaultCoefficients()
+		fit, err := FitAudio(syntheticAudioSamples(target), initial)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Audio.IeIntercept", "Audio.IeSlope", "Audio.IeDtx", "Audio.Bpl", "Audio.BplFec", "Audio.BplRed"}, fit.Fitted)
+		t.Log("before", fit.Before, "after", fit.After)
+		require.InDelta(t, 60, fit.Coefficients.Audio.IeIntercept, 3)
+		require.InDelta(t, 5, fit.Coefficients.Audio.IeSlope, 0.3)
+		require.InDelta(t, 12, fit.Coefficients.Audio.IeDtx, 1)
+		require.InDelta(t, 6, fit.Coefficients.Audio.Bpl, 0.5)
+		require.InDelta(t, 15, fit.Coefficients.Audio.BplFec, 1)
+		require.InDelta(t, 50, fit.Coefficients.Audio.BplRed, 5)
+		require.Less(t, fit.After.RMSE, 0.05)
+		require.Less(t, fit.After.RMSE, fit.Before.RMSE)
+
+		// one entry per combination of flags, fitted on its own samples
+		require.Len(t, fit.Configurations, 4)
+		red := fit.Configurations[1]
+		require.True(t, red.Red)
+		require.True(t, red.Fitted)
+		require.Equal(t, rtcmos.AudioBandwidthNarrowband, red.Bandwidth)
+		require.Equal(t, 24, red.Samples)
+		// Ie at the median bitrate of 32 kbps
+		require.InDelta(t, 60-5*math.Log(32000), red.Ie, 1)
+		require.InDelta(t, 50, red.Bpl, 5)
+		require.InDelta(t, 12, fit.Configurations[3].Ie, 1)
+		require.InDelta(t, 15, fit.Configurations[2].Bpl, 1)
+
+		// the fitted configurations are stored in the coefficients
+		require.Len(t, fit.Coefficients.Audio.Configurations, 4)
+		fitted := fit.Coefficients.Audio.Configurations[1]
+		require.True(t, fitted.Red)
+		require.Equal(t, rtcmos.AudioBandwidthNarrowband, fitted.Bandwidth)
+		require.InDelta(t, red.Bpl, fitted.Bpl, 1e-9)
+		require.NoError(t, fit.Coefficients.Validate())
+
+		// the initial coefficients are left untouched
+		require.Equal(t, rtcmos.DefaultCoefficients(), initial)
+	}
+	{
+		// impairments of a configuration differing from the global ones are used by audio scoring
+		target := target.Clone()
+		target.Audio.Configurations = []rtcmos.AudioConfigurationCoefficients{
+			{Fec: true, IeIntercept: 70, IeDtx: 12, IeUnknownBitrate: 6, Bpl: 30},
+		}
+		initial := rtcmos.DefaultCoefficients()
+		initial.Audio.Configurations = []rtcmos.AudioConfigurationCoefficients{
+			{Fec: true, Bandwidth: rtcmos.AudioBandwidthFullband, IeIntercept: 50, IeDtx: 8, Bpl: 20},
+		}
+		fit, err := FitAudio(syntheticAudioSamples(target), initial)
+		require.NoError(t, err)
+		require.Less(t, fit.After.RMSE, 0.05)
+
+		// the fullband configuration is not in the samples and is kept
+		require.Len(t, fit.Coefficients.Audio.Configurations, 5)
+		require.Equal(t, initial.Audio.Configurations[0], fit.Coefficients.Audio.Configurations[3])
+		fec := fit.Coefficients.Audio.Configurations[2]
+		require.True(t, fec.Fec)
+		require.False(t, fec.Red)
+		require.InDelta(t, 70, fec.IeIntercept, 3)
+		require.InDelta(t, 30, fec.Bpl, 2)
+
+		// and load back from a file
+		path := filepath.Join(t.TempDir(), "coefficients.yaml")
+		require.NoError(t, rtcmos.SaveCoefficients(path, fit.Coefficients))
+		loaded, err := rtcmos.LoadCoefficients(path)
+		require.NoError(t, err)
+		require.Equal(t, fit.Coefficients.Audio.Configurations, loaded.Audio.Configurations)
+	}
+	{
+		// coefficients the samples do not depend on keep their initial value
+		var samples []Sample
+		for _, sample := range syntheticAudioSamples(target) {
+			if !*sample.Stat.AudioConfig.Red && !*sample.Stat.AudioConfig.Dtx && *sample.Stat.AudioConfig.Fec {
+				samples = append(samples, sample)
+			}
+		}
+		fit, err := FitAudio(samples, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Audio.IeIntercept", "Audio.IeSlope", "Audio.BplFec"}, fit.Fitted)
+		require.Equal(t, 10.0, fit.Coefficients.Audio.Bpl)
+		require.Equal(t, 90.0, fit.Coefficients.Audio.BplRed)
+	}
+	{
+		// configurations with few samples are derived from the global fit, bandwidths are separate configurations
+		samples := syntheticAudioSamples(target)
+		for _, sample := range samples[:MinConfigurationSamples-1] {
+			audioConfig := *sample.Stat.AudioConfig
+			audioConfig.Bandwidth = rtcmos.AudioBandwidthWideband
+			sample.Stat.AudioConfig = &audioConfig
+			sample.MOS = rtcmos.AudioScoreWith(sample.Stat, target).AudioScore
+			samples = append(samples, sample)
+		}
+		fit, err := FitAudio(samples, nil)
+		require.NoError(t, err)
+		require.Len(t, fit.Configurations, 5)
+		wideband := fit.Configurations[1]
+		require.Equal(t, rtcmos.AudioBandwidthWideband, wideband.Bandwidth)
+		require.Equal(t, MinConfigurationSamples-1, wideband.Samples)
+		require.False(t, wideband.Fitted)
+		require.Equal(t, fit.Coefficients.Audio.Bpl, wideband.Bpl)
+		require.True(t, fit.Configurations[0].Fitted)
+	}
+	{
+		// not enough samples
+		_, err := FitAudio(syntheticAudioSamples(target)[:2], nil)
+		require.ErrorIs(t, err, ErrSingular)
+		_, err = FitAudio(syntheticVideoSamples(target), nil)
+		require.ErrorIs(t, err, ErrSingular)
+	}
+}
+
+func TestCrossValidate(t *testing.T) {
+	target := rtcmos.DefaultCoefficients()
+	target.Audio.IeIntercept = 60
+	target.Audio.BplFec = 15
+	fitAudio := func(samples []Sample) (*rtcmos.Coefficients, error) {
+		fit, err := FitAudio(samples, nil)
+		if err != nil {
+			return nil, err
+		}
+		return fit.Coefficients, nil
+	}
+	{
+		// the model generalizes to samples left out of the fit
+		metrics, err := CrossValidate(syntheticAudioSamples(target), 5, fitAudio)
+		require.NoError(t, err)
+		require.Equal(t, 96, metrics.Samples)
+		require.Less(t, metrics.RMSE, 0.1)
+		require.Greater(t, metrics.Pearson, 0.95)
+	}
+	{
+		// invalid number of folds
+		_, err := CrossValidate(syntheticAudioSamples(target), 1, fitAudio)
+		require.Error(t, err)
+		_, err = CrossValidate(syntheticAudioSamples(target)[:3], 4, fitAudio)
+		require.Error(t, err)
+	}
+	{
+		// fit errors are reported
+		_, err := CrossValidate(syntheticAudioSamples(target)[:4], 2, fitAudio)
+		require.ErrorIs(t, err, ErrSingular)
+	}
+}
@@ -0,0 +1,39 @@
+package calibrate
+
+import (
+	"fmt"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+// Fitter fits coefficients to samples, e.g. a closure over FitAudio or FitVideo
+type Fitter func(samples []Sample) (*rtcmos.Coefficients, error)
+
+// CrossValidate measures how well the fitter generalizes with k-fold cross validation:
+// each fold is scored with coefficients fitted on the other folds, samples are assigned to folds round robin
+func CrossValidate(samples []Sample, folds int, fit Fitter) (Metrics, error) {
+	if folds < 2 || folds > len(samples) {
+		return Metrics{}, fmt.Errorf("cannot split %d samples in %d folds", len(samples), folds)
+	}
+
+	var predicted, observed []float64
+	for fold := 0; fold < folds; fold++ {
+		var training, validation []Sample
+		for i, sample := range samples {
+			if i%folds == fold {
+				validation = append(validation, sample)
+			} else {
+				training = append(training, sample)
+			}
+		}
+
+		coefficients, err := fit(training)
+		if err != nil {
+			return Metrics{}, fmt.Errorf("fold %d: %w", fold, err)
+		}
+		p, o := predict(validation, coefficients)
+		predicted = append(predicted, p...)
+		observed = append(observed, o...)
+	}
+	return ComputeMetrics(predicted, observed), nil
+}
@@ -0,0 +1,289 @@
+package calibrate
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+// Sample is a labeled observation: the stat of a session and its subjective MOS (e.g. ACR rating)
+type Sample struct {
+	Stat rtcmos.Stat `json:"stat"`
+	MOS  float64     `json:"mos"`
+}
+
+// ReadSamples reads a dataset file, CSV for .csv extensions, JSON lines otherwise
+func ReadSamples(path string) ([]Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []Sample
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		samples, err = ReadCSV(f)
+	} else {
+		samples, err = ReadJSONL(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read samples from %s: %w", path, err)
+	}
+	return samples, nil
+}
+
+// ReadJSONL reads one JSON encoded Sample per line, empty lines are skipped
+func ReadJSONL(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var sample Sample
+		if err := json.Unmarshal([]byte(text), &sample); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := validateSample(sample); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// videoColumns - columns which make a row without a "kind" column a video sample
+var videoColumns = map[string]bool{
+	"width":                  true,
+	"height":                 true,
+	"frame_rate":             true,
+	"expected_frame_rate":    true,
+	"nack":                   true,
+	"freeze_count":           true,
+	"total_freezes_duration": true,
+}
+
+// csvColumns - setters of the Stat fields for each supported CSV column,
+// the audio or video config of the sample is created before the setters run
+var csvColumns = map[string]func(sample *Sample, value string) error{
+	"kind": func(sample *Sample, value string) error {
+		return nil
+	},
+	"mos": func(sample *Sample, value string) (err error) {
+		sample.MOS, err = parseFinite(value, 64)
+		return
+	},
+	"bitrate": func(sample *Sample, value string) error {
+		return parseFloat32(value, &sample.Stat.Bitrate)
+	},
+	"packet_loss": func(sample *Sample, value string) error {
+		return parseFloat32(value, &sample.Stat.PacketLoss)
+	},
+	"jitter": func(sample *Sample, value string) error {
+		return parseFloat32(value, &sample.Stat.Jitter)
+	},
+	"round_trip_time": func(sample *Sample, value string) error {
+		return parseInt32Ptr(value, &sample.Stat.RoundTripTime)
+	},
+	"buffer_delay": func(sample *Sample, value string) error {
+		return parseInt32Ptr(value, &sample.Stat.BufferDelay)
+	},
+	"interval": func(sample *Sample, value string) error {
+		return parseInt32Ptr(value, &sample.Stat.Interval)
+	},
+	"codec": func(sample *Sample, value string) error {
+		if audioConfig := sample.Stat.AudioConfig; audioConfig != nil {
+			audioConfig.Codec = rtcmos.AudioCodec(value)
+		} else {
+			sample.Stat.VideoConfig.Codec = value
+		}
+		return nil
+	},
+	"fec": func(sample *Sample, value string) error {
+		if audioConfig := sample.Stat.AudioConfig; audioConfig != nil {
+			return parseBoolPtr(value, &audioConfig.Fec)
+		}
+		return parseBoolPtr(value, &sample.Stat.VideoConfig.Fec)
+	},
+	"red": func(sample *Sample, value string) error {
+		if audioConfig := sample.Stat.AudioConfig; audioConfig != nil {
+			return parseBoolPtr(value, &audioConfig.Red)
+		}
+		return parseBoolPtr(value, &sample.Stat.VideoConfig.Red)
+	},
+	"dtx": func(sample *Sample, value string) error {
+		return parseBoolPtr(value, &audioConfig(sample).Dtx)
+	},
+	"bandwidth": func(sample *Sample, value string) error {
+		audioConfig(sample).Bandwidth = rtcmos.AudioBandwidth(value)
+		return nil
+	},
+	"model": func(sample *Sample, value string) error {
+		audioConfig(sample).Model = rtcmos.AudioModel(value)
+		return nil
+	},
+	"width": func(sample *Sample, value string) error {
+		return parseInt32Ptr(value, &videoConfig(sample).Width)
+	},
+	"height": func(sample *Sample, value string) error {
+		return parseInt32Ptr(value, &videoConfig(sample).Height)
+	},
+	"frame_rate": func(sample *Sample, value string) error {
+		return parseFloat32Ptr(value, &videoConfig(sample).FrameRate)
+	},
+	"expected_frame_rate": func(sample *Sample, value string) error {
+		return parseFloat32Ptr(value, &videoConfig(sample).ExpectedFrameRate)
+	},
+	"nack": func(sample *Sample, value string) error {
+		return parseBoolPtr(value, &videoConfig(sample).Nack)
+	},
+	"freeze_count": func(sample *Sample, value string) error {
+		return parseInt32Ptr(value, &videoConfig(sample).FreezeCount)
+	},
+	"total_freezes_duration": func(sample *Sample, value string) error {
+		return parseInt32Ptr(value, &videoConfig(sample).TotalFreezesDuration)
+	},
+}
+
+// ReadCSV reads samples from CSV with a header row naming the columns, "mos" is required and
+// empty cells keep the defaults of the Stat. Rows are audio or video according to the "kind" column,
+// or video when a video only column (e.g. "width") is set.
+func ReadCSV(r io.Reader) ([]Sample, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	hasMOS := false
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := csvColumns[header[i]]; !ok {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+		hasMOS = hasMOS || header[i] == "mos"
+	}
+	if !hasMOS {
+		return nil, errors.New("missing column \"mos\"")
+	}
+
+	var samples []Sample
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		var sample Sample
+		kind := "audio"
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if header[i] == "kind" && value != "" {
+				kind = strings.ToLower(value)
+				break
+			}
+			if videoColumns[header[i]] && value != "" {
+				kind = "video"
+			}
+		}
+		switch kind {
+		case "audio":
+			sample.Stat.AudioConfig = &rtcmos.AudioConfig{}
+		case "video":
+			sample.Stat.VideoConfig = &rtcmos.VideoConfig{}
+		default:
+			return nil, fmt.Errorf("line %d: unknown kind %q", line, kind)
+		}
+
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			if err := csvColumns[header[i]](&sample, value); err != nil {
+				return nil, fmt.Errorf("line %d, column %s: %w", line, header[i], err)
+			}
+		}
+		if err := validateSample(sample); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+func validateSample(sample Sample) error {
+	// written so that NaN is rejected too
+	if !(sample.MOS >= 1 && sample.MOS <= 5) {
+		return fmt.Errorf("mos %v is not between 1 and 5", sample.MOS)
+	}
+	return sample.Stat.Validate()
+}
+
+func audioConfig(sample *Sample) *rtcmos.AudioConfig {
+	if sample.Stat.AudioConfig == nil {
+		sample.Stat.AudioConfig = &rtcmos.AudioConfig{}
+	}
+	return sample.Stat.AudioConfig
+}
+
+func videoConfig(sample *Sample) *rtcmos.VideoConfig {
+	if sample.Stat.VideoConfig == nil {
+		sample.Stat.VideoConfig = &rtcmos.VideoConfig{}
+	}
+	return sample.Stat.VideoConfig
+}
+
+// parseFinite - parses a float, NaN and infinite values are rejected
+func parseFinite(value string, bitSize int) (float64, error) {
+	f, err := strconv.ParseFloat(value, bitSize)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		err = fmt.Errorf("%q is not a finite number", value)
+	}
+	return f, err
+}
+
+func parseFloat32(value string, dst *float32) error {
+	f, err := parseFinite(value, 32)
+	*dst = float32(f)
+	return err
+}
+
+func parseFloat32Ptr(value string, dst **float32) error {
+	f, err := parseFinite(value, 32)
+	v := float32(f)
+	*dst = &v
+	return err
+}
+
+func parseInt32Ptr(value string, dst **int32) error {
+	i, err := strconv.ParseInt(value, 10, 32)
+	v := int32(i)
+	*dst = &v
+	return err
+}
+
+func parseBoolPtr(value string, dst **bool) error {
+	b, err := strconv.ParseBool(value)
+	*dst = &b
+	return err
+}
@@ -0,0 +1,78 @@
+package calibrate
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrSingular is returned when the samples do not determine the coefficients
+var ErrSingular = errors.New("samples do not determine the coefficients, more varied data is needed")
+
+// leastSquares - solves min |X b - y|² through the normal equations
+func leastSquares(X [][]float64, y []float64) ([]float64, error) {
+	if len(X) == 0 {
+		return nil, ErrSingular
+	}
+	n := len(X[0])
+
+	// augmented matrix [XᵀX | Xᵀy]
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n+1)
+	}
+	for r, row := range X {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a[i][j] += row[i] * row[j]
+			}
+			a[i][n] += row[i] * y[r]
+		}
+	}
+
+	// gaussian elimination with partial pivoting
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, ErrSingular
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for r := col + 1; r < n; r++ {
+			factor := a[r][col] / a[col][col]
+			for c := col; c <= n; c++ {
+				a[r][c] -= factor * a[col][c]
+			}
+		}
+	}
+
+	b := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := a[i][n]
+		for j := i + 1; j < n; j++ {
+			sum -= a[i][j] * b[j]
+		}
+		b[i] = sum / a[i][i]
+	}
+	return b, nil
+}
+
+// variance - population variance of the values
+func variance(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var mean, squares float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return squares / float64(len(values))
+}
@@ -0,0 +1,81 @@
+package calibrate
+
+import (
+	"math"
+	"sort"
+)
+
+// Metrics contains the goodness of fit of predicted against subjective scores
+type Metrics struct {
+	// Samples: number of samples compared
+	Samples int `json:"samples"`
+	// RMSE: root mean square error
+	RMSE float64 `json:"rmse"`
+	// Pearson: linear correlation coefficient
+	Pearson float64 `json:"pearson"`
+	// Spearman: rank correlation coefficient
+	Spearman float64 `json:"spearman"`
+}
+
+// ComputeMetrics compares predicted and observed scores of the same length
+func ComputeMetrics(predicted, observed []float64) Metrics {
+	metrics := Metrics{Samples: len(observed)}
+	if len(predicted) != len(observed) || len(observed) == 0 {
+		return metrics
+	}
+
+	var squares float64
+	for i := range observed {
+		squares += (predicted[i] - observed[i]) * (predicted[i] - observed[i])
+	}
+	metrics.RMSE = math.Sqrt(squares / float64(len(observed)))
+	metrics.Pearson = pearson(predicted, observed)
+	metrics.Spearman = pearson(ranks(predicted), ranks(observed))
+	return metrics
+}
+
+// pearson - linear correlation coefficient, 0 when a series is constant
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i] / n
+		meanY += y[i] / n
+	}
+
+	var covariance, varianceX, varianceY float64
+	for i := range x {
+		covariance += (x[i] - meanX) * (y[i] - meanY)
+		varianceX += (x[i] - meanX) * (x[i] - meanX)
+		varianceY += (y[i] - meanY) * (y[i] - meanY)
+	}
+	if varianceX == 0 || varianceY == 0 {
+		return 0
+	}
+	return covariance / math.Sqrt(varianceX*varianceY)
+}
+
+// ranks - fractional ranks, ties get the average of their ranks
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+
+	result := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			result[order[k]] = rank
+		}
+		i = j + 1
+	}
+	return result
+}
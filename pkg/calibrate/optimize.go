@@ -0,0 +1,86 @@
+package calibrate
+
+import (
+	"math"
+	"sort"
+)
+
+// nelderMead - minimizes f starting from x0 with the downhill simplex method,
+// step is the initial size of the simplex along each dimension
+func nelderMead(f func(x []float64) float64, x0 []float64, step float64, iterations int) []float64 {
+	n := len(x0)
+	if n == 0 {
+		return x0
+	}
+
+	type vertex struct {
+		x     []float64
+		value float64
+	}
+	simplex := make([]vertex, n+1)
+	for i := range simplex {
+		x := append([]float64(nil), x0...)
+		if i > 0 {
+			x[i-1] += step
+		}
+		simplex[i] = vertex{x: x, value: f(x)}
+	}
+
+	// moves the centroid away from the worst vertex by the factor
+	along := func(centroid, worst []float64, factor float64) []float64 {
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = centroid[i] + factor*(worst[i]-centroid[i])
+		}
+		return x
+	}
+
+	for iteration := 0; iteration < iterations; iteration++ {
+		sort.Slice(simplex, func(i, j int) bool {
+			return simplex[i].value < simplex[j].value
+		})
+		if math.Abs(simplex[n].value-simplex[0].value) < 1e-10 {
+			break
+		}
+
+		centroid := make([]float64, n)
+		for _, v := range simplex[:n] {
+			for i := range centroid {
+				centroid[i] += v.x[i] / float64(n)
+			}
+		}
+
+		worst := simplex[n]
+		reflected := along(centroid, worst.x, -1)
+		reflectedValue := f(reflected)
+		switch {
+		case reflectedValue < simplex[0].value:
+			expanded := along(centroid, worst.x, -2)
+			if expandedValue := f(expanded); expandedValue < reflectedValue {
+				simplex[n] = vertex{x: expanded, value: expandedValue}
+			} else {
+				simplex[n] = vertex{x: reflected, value: reflectedValue}
+			}
+		case reflectedValue < simplex[n-1].value:
+			simplex[n] = vertex{x: reflected, value: reflectedValue}
+		default:
+			contracted := along(centroid, worst.x, 0.5)
+			if contractedValue := f(contracted); contractedValue < worst.value {
+				simplex[n] = vertex{x: contracted, value: contractedValue}
+				continue
+			}
+			// shrink towards the best vertex
+			for k := 1; k <= n; k++ {
+				for i := range simplex[k].x {
+					simplex[k].x[i] = simplex[0].x[i] + 0.5*(simplex[k].x[i]-simplex[0].x[i])
+				}
+				simplex[k].value = f(simplex[k].x)
+			}
+		}
+	}
+
+	sort.Slice(simplex, func(i, j int) bool {
+		return simplex[i].value < simplex[j].value
+	})
+	return simplex[0].x
+}
@@ -0,0 +1,211 @@
+package calibrate
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+// samples whose loss and freezes leave less than this share of the quality carry little information on the regression
+const minQualityKept = 0.2
+
+// VideoFit is the result of FitVideo
+type VideoFit struct {
+	// Coefficients: initial coefficients with the fitted video regression
+	Coefficients *rtcmos.Coefficients
+	// Fitted: names of the fitted coefficients, the others keep their initial value
+	Fitted []string
+	// Before, After: goodness of fit on the video samples with the initial and the fitted coefficients
+	Before Metrics
+	After  Metrics
+}
+
+// videoTerm - a coefficient of the video regression and its regressor, the score being linear in the coefficients
+type videoTerm struct {
+	name  string
+	value func(c *rtcmos.VideoCoefficients) *float64
+	x     func(f rtcmos.VideoFeatures) float64
+	// optional terms are kept at their initial value when the samples do not vary along them
+	optional bool
+}
+
+var videoTerms = []videoTerm{
+	{
+		name:  "Video.BPPPFSlope",
+		value: func(c *rtcmos.VideoCoefficients) *float64 { return &c.BPPPFSlope },
+		x:     func(f rtcmos.VideoFeatures) float64 { return math.Log(f.BPPPF) },
+	},
+	{
+		name:  "Video.BPPPFIntercept",
+		value: func(c *rtcmos.VideoCoefficients) *float64 { return &c.BPPPFIntercept },
+		x:     func(f rtcmos.VideoFeatures) float64 { return 1 },
+	},
+	{
+		name:     "Video.FrameRateFactor",
+		value:    func(c *rtcmos.VideoCoefficients) *float64 { return &c.FrameRateFactor },
+		x:        func(f rtcmos.VideoFeatures) float64 { return -math.Log(f.FrameRateRatio) },
+		optional: true,
+	},
+	{
+		name:     "Video.DelayFactor",
+		value:    func(c *rtcmos.VideoCoefficients) *float64 { return &c.DelayFactor },
+		x:        func(f rtcmos.VideoFeatures) float64 { return -f.Delay },
+		optional: true,
+	},
+}
+
+// FitVideo fits the bPPPF regression, frame rate and delay coefficients of the video model to the video samples
+// by least squares, starting from the initial coefficients (DefaultCoefficients when nil).
+//
+// The loss and freeze impairments scale the regression and are kept as is, the subjective scores are brought
+// back to the regression scale before fitting.
+func FitVideo(samples []Sample, initial *rtcmos.Coefficients) (*VideoFit, error) {
+	if initial == nil {
+		initial = rtcmos.DefaultCoefficients()
+	}
+
+	var features []rtcmos.VideoFeatures
+	var targets []float64
+	for _, sample := range samples {
+		f, ok := rtcmos.ExtractVideoFeatures(sample.Stat, initial)
+		if !ok || f.QualityKept < minQualityKept {
+			continue
+		}
+		features = append(features, f)
+		targets = append(targets, 1+(sample.MOS-1)/f.QualityKept)
+	}
+
+	// drop the optional terms the samples cannot determine, or which would fit with the wrong sign
+	fitted := make([]bool, len(videoTerms))
+	for i, term := range videoTerms {
+		fitted[i] = !term.optional || variance(columnOf(features, term)) > 1e-9
+	}
+
+	coefficients := initial.Clone()
+	for {
+		solution, err := fitVideoTerms(features, targets, fitted, &coefficients.Video)
+		if err != nil {
+			return nil, err
+		}
+
+		retry := false
+		for i, term := range videoTerms {
+			if !fitted[i] {
+				continue
+			}
+			if term.optional && solution[i] < 0 {
+				fitted[i] = false
+				*term.value(&coefficients.Video) = 0
+				retry = true
+			}
+		}
+		if retry {
+			continue
+		}
+
+		for i, term := range videoTerms {
+			if fitted[i] {
+				*term.value(&coefficients.Video) = solution[i]
+			}
+		}
+		break
+	}
+
+	if err := coefficients.Validate(); err != nil {
+		return nil, fmt.Errorf("fitted coefficients are not usable: %w", err)
+	}
+
+	fit := &VideoFit{
+		Coefficients: coefficients,
+		Before:       Measure(videoSamples(samples), initial),
+		After:        Measure(videoSamples(samples), coefficients),
+	}
+	for i, term := range videoTerms {
+		if fitted[i] {
+			fit.Fitted = append(fit.Fitted, term.name)
+		}
+	}
+	return fit, nil
+}
+
+// fitVideoTerms - least squares on the fitted terms, the contribution of the others being removed from the targets
+func fitVideoTerms(features []rtcmos.VideoFeatures, targets []float64, fitted []bool, c *rtcmos.VideoCoefficients) (map[int]float64, error) {
+	var columns []int
+	for i := range videoTerms {
+		if fitted[i] {
+			columns = append(columns, i)
+		}
+	}
+	if len(features) <= len(columns) {
+		return nil, fmt.Errorf("%w: %d usable video samples for %d coefficients", ErrSingular, len(features), len(columns))
+	}
+
+	X := make([][]float64, len(features))
+	y := make([]float64, len(features))
+	for r, f := range features {
+		y[r] = targets[r]
+		for i, term := range videoTerms {
+			if fitted[i] {
+				X[r] = append(X[r], term.x(f))
+			} else {
+				y[r] -= *term.value(c) * term.x(f)
+			}
+		}
+	}
+
+	b, err := leastSquares(X, y)
+	if err != nil {
+		return nil, err
+	}
+	solution := make(map[int]float64, len(columns))
+	for k, i := range columns {
+		solution[i] = b[k]
+	}
+	return solution, nil
+}
+
+func columnOf(features []rtcmos.VideoFeatures, term videoTerm) []float64 {
+	column := make([]float64, len(features))
+	for i, f := range features {
+		column[i] = term.x(f)
+	}
+	return column
+}
+
+func videoSamples(samples []Sample) []Sample {
+	var result []Sample
+	for _, sample := range samples {
+		if sample.Stat.VideoConfig != nil {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
+
+// Measure compares the scores predicted with the coefficients to the subjective scores of the samples
+func Measure(samples []Sample, coefficients *rtcmos.Coefficients) Metrics {
+	return ComputeMetrics(predict(samples, coefficients))
+}
+
+// predict - scores of the valid samples with the coefficients and their subjective scores
+func predict(samples []Sample, coefficients *rtcmos.Coefficients) ([]float64, []float64) {
+	stats := make([]rtcmos.Stat, len(samples))
+	for i, sample := range samples {
+		stats[i] = sample.Stat
+	}
+
+	var predicted, observed []float64
+	for i, result := range rtcmos.EvaluateWith(rtcmos.NewScorer(coefficients), stats) {
+		if result.Err != nil {
+			continue
+		}
+		if samples[i].Stat.AudioConfig != nil {
+			predicted = append(predicted, result.Scores.AudioScore)
+		} else {
+			predicted = append(predicted, result.Scores.VideoScore)
+		}
+		observed = append(observed, samples[i].MOS)
+	}
+	return predicted, observed
+}
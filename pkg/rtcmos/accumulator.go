@@ -0,0 +1,190 @@
+package rtcmos
+
+import (
+	"math"
+)
+
+// Counters is a snapshot of the cumulative counters of a received stream, e.g. from RTCInboundRtpStreamStats
+type Counters struct {
+	// Timestamp: time of the snapshot (ms)
+	Timestamp float64
+
+	PacketsReceived  uint64
+	PacketsLost      int64
+	PacketsDiscarded uint64
+	BytesReceived    uint64
+	FramesDecoded    uint64
+	FreezeCount      uint64
+	// TotalFreezesDuration, TotalPausesDuration: (ms)
+	TotalFreezesDuration float64
+	TotalPausesDuration  float64
+	// JitterBufferDelay: sum of the time spent in the jitter buffer by the emitted samples or frames (ms)
+	JitterBufferDelay        float64
+	JitterBufferEmittedCount uint64
+
+	// Jitter: interarrival jitter at the time of the snapshot (ms)
+	Jitter float32
+	// RoundTripTime: round trip time at the time of the snapshot (ms), optional
+	RoundTripTime *int32
+	// AudioConfig, VideoConfig: configuration of the stream, copied to the emitted Stat. The frame rate and freeze
+	// fields of VideoConfig are computed from the counters.
+	AudioConfig *AudioConfig
+	VideoConfig *VideoConfig
+}
+
+// Accumulator turns the cumulative counters of a stream into a Stat per window.
+//
+// A counter going backwards is a wraparound when WrapBits is set and the drop is larger than half the counter
+// range, and a reset of the stream otherwise (e.g. restart, new SSRC) in which case the new values are counted
+// from zero. Snapshots older than the latest one are out of order and ignored.
+type Accumulator struct {
+	// Window: minimum duration of the emitted Stat (ms), a Stat is emitted for every snapshot when 0
+	Window float64
+	// WrapBits: width of the counters of the source, e.g. 32, counters are not expected to wrap when 0
+	WrapBits uint
+
+	last   *Counters
+	window windowCounters
+}
+
+// windowCounters - counters accumulated since the start of the window
+type windowCounters struct {
+	start           float64
+	received        uint64
+	lost            int64
+	discarded       uint64
+	bytes           uint64
+	frames          uint64
+	freezes         uint64
+	freezesDuration float64
+	pausesDuration  float64
+	bufferDelay     float64
+	emitted         uint64
+	jitterSum       float64
+	snapshots       int
+	roundTripTime   *int32
+}
+
+// NewAccumulator returns an Accumulator emitting a Stat every window (ms)
+func NewAccumulator(window float64) *Accumulator {
+	return &Accumulator{Window: window}
+}
+
+// Add ingests a snapshot, returns the Stat of the window and true when the window is complete
+func (a *Accumulator) Add(counters Counters) (Stat, bool) {
+	if a.last == nil {
+		a.last = &counters
+		a.window = windowCounters{start: counters.Timestamp}
+		return Stat{}, false
+	}
+	if counters.Timestamp <= a.last.Timestamp {
+		return Stat{}, false
+	}
+
+	a.accumulate(a.last, &counters)
+	a.last = &counters
+	if counters.Timestamp-a.window.start < a.Window {
+		return Stat{}, false
+	}
+
+	stat := a.window.stat(counters.Timestamp, &counters)
+	a.window = windowCounters{start: counters.Timestamp}
+	return stat, true
+}
+
+// accumulate - adds the deltas between two consecutive snapshots to the window
+func (a *Accumulator) accumulate(prev, cur *Counters) {
+	received, resetReceived := a.delta(prev.PacketsReceived, cur.PacketsReceived)
+	discarded, resetDiscarded := a.delta(prev.PacketsDiscarded, cur.PacketsDiscarded)
+	bytes, resetBytes := a.delta(prev.BytesReceived, cur.BytesReceived)
+	frames, resetFrames := a.delta(prev.FramesDecoded, cur.FramesDecoded)
+	freezes, resetFreezes := a.delta(prev.FreezeCount, cur.FreezeCount)
+	emitted, resetEmitted := a.delta(prev.JitterBufferEmittedCount, cur.JitterBufferEmittedCount)
+	reset := resetReceived || resetDiscarded || resetBytes || resetFrames || resetFreezes || resetEmitted ||
+		cur.TotalFreezesDuration < prev.TotalFreezesDuration ||
+		cur.TotalPausesDuration < prev.TotalPausesDuration ||
+		cur.JitterBufferDelay < prev.JitterBufferDelay
+
+	w := &a.window
+	if reset {
+		// the counters restarted from zero during the interval
+		w.received += cur.PacketsReceived
+		w.lost += cur.PacketsLost
+		w.discarded += cur.PacketsDiscarded
+		w.bytes += cur.BytesReceived
+		w.frames += cur.FramesDecoded
+		w.freezes += cur.FreezeCount
+		w.freezesDuration += cur.TotalFreezesDuration
+		w.pausesDuration += cur.TotalPausesDuration
+		w.bufferDelay += cur.JitterBufferDelay
+		w.emitted += cur.JitterBufferEmittedCount
+	} else {
+		w.received += received
+		// packetsLost decreases when duplicates or late packets arrive
+		w.lost += cur.PacketsLost - prev.PacketsLost
+		w.discarded += discarded
+		w.bytes += bytes
+		w.frames += frames
+		w.freezes += freezes
+		w.freezesDuration += cur.TotalFreezesDuration - prev.TotalFreezesDuration
+		w.pausesDuration += cur.TotalPausesDuration - prev.TotalPausesDuration
+		w.bufferDelay += cur.JitterBufferDelay - prev.JitterBufferDelay
+		w.emitted += emitted
+	}
+
+	w.jitterSum += float64(cur.Jitter)
+	w.snapshots++
+	if cur.RoundTripTime != nil {
+		w.roundTripTime = int32Ptr(*cur.RoundTripTime)
+	}
+}
+
+// delta - increase of a counter, true when the counter was reset
+func (a *Accumulator) delta(prev, cur uint64) (uint64, bool) {
+	if cur >= prev {
+		return cur - prev, false
+	}
+	if a.WrapBits > 0 && a.WrapBits < 64 {
+		limit := uint64(1) << a.WrapBits
+		if prev < limit && prev-cur > limit/2 {
+			return cur + limit - prev, false
+		}
+	}
+	return 0, true
+}
+
+// stat - Stat of the window ending at the timestamp
+func (w *windowCounters) stat(end float64, last *Counters) Stat {
+	interval := end - w.start
+	stat := Stat{
+		Bitrate:       float32(float64(w.bytes) * 8 * 1000 / interval),
+		RoundTripTime: w.roundTripTime,
+		Interval:      int32Ptr(int32(math.Round(interval))),
+		Jitter:        float32(w.jitterSum / float64(w.snapshots)),
+	}
+
+	lost := math.Max(0, float64(w.lost))
+	if received := float64(w.received); received+lost > 0 {
+		stat.PacketLoss = float32(lost * 100 / (received + lost))
+	}
+	if w.received > 0 {
+		stat.JitterBufferDiscard = float32(math.Min(100, float64(w.discarded)*100/float64(w.received)))
+	}
+	if w.emitted > 0 {
+		stat.BufferDelay = int32Ptr(int32(math.Round(w.bufferDelay / float64(w.emitted))))
+	}
+
+	if last.AudioConfig != nil {
+		audioConfig := *last.AudioConfig
+		stat.AudioConfig = &audioConfig
+	}
+	if last.VideoConfig != nil {
+		videoConfig := *last.VideoConfig
+		videoConfig.FrameRate = float32Ptr(float32(float64(w.frames) * 1000 / interval))
+		videoConfig.FreezeCount = int32Ptr(int32(w.freezes))
+		videoConfig.TotalFreezesDuration = int32Ptr(int32(math.Round(w.freezesDuration)))
+		videoConfig.TotalPausesDuration = int32Ptr(int32(math.Round(w.pausesDuration)))
+		stat.VideoConfig = &videoConfig
+	}
+	return stat
+}
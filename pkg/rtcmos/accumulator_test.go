@@ -0,0 +1,108 @@
+package rtcmos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccumulator(t *testing.T) {
+	{
+		// every snapshot after the first one is emitted without window
+		accumulator := NewAccumulator(0)
+		_, ok := accumulator.Add(Counters{Timestamp: 1000, PacketsReceived: 100, BytesReceived: 10000, AudioConfig: &AudioConfig{}})
+		require.False(t, ok)
+
+		stat, ok := accumulator.Add(Counters{
+			Timestamp:                2000,
+			PacketsReceived:          145,
+			PacketsLost:              5,
+			PacketsDiscarded:         1,
+			BytesReceived:            14000,
+			JitterBufferDelay:        3840000,
+			JitterBufferEmittedCount: 48000,
+			Jitter:                   12,
+			RoundTripTime:            int32Ptr(90),
+			AudioConfig:              &AudioConfig{Fec: boolPtr(false)},
+		})
+		require.True(t, ok)
+		require.InDelta(t, 10, stat.PacketLoss, 1e-4)
+		require.InDelta(t, 32000, stat.Bitrate, 1e-3)
+		require.InDelta(t, 100.0/45, stat.JitterBufferDiscard, 1e-4)
+		require.Equal(t, int32(80), *stat.BufferDelay)
+		require.Equal(t, int32(90), *stat.RoundTripTime)
+		require.Equal(t, int32(1000), *stat.Interval)
+		require.Equal(t, float32(12), stat.Jitter)
+		require.False(t, *stat.AudioConfig.Fec)
+		require.NoError(t, stat.Validate())
+	}
+	{
+		// snapshots are accumulated until the window is complete, out of order snapshots are ignored
+		accumulator := NewAccumulator(2000)
+		videoConfig := &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720)}
+		_, ok := accumulator.Add(Counters{Timestamp: 0, VideoConfig: videoConfig})
+		require.False(t, ok)
+		_, ok = accumulator.Add(Counters{Timestamp: 1000, PacketsReceived: 100, BytesReceived: 250000, FramesDecoded: 30, Jitter: 4, VideoConfig: videoConfig})
+		require.False(t, ok)
+		_, ok = accumulator.Add(Counters{Timestamp: 500, PacketsReceived: 50, VideoConfig: videoConfig})
+		require.False(t, ok)
+
+		stat, ok := accumulator.Add(Counters{
+			Timestamp:            2000,
+			PacketsReceived:      200,
+			BytesReceived:        500000,
+			FramesDecoded:        50,
+			FreezeCount:          1,
+			TotalFreezesDuration: 400,
+			Jitter:               8,
+			VideoConfig:          videoConfig,
+		})
+		require.True(t, ok)
+		require.Equal(t, float32(0), stat.PacketLoss)
+		require.InDelta(t, 2000000, stat.Bitrate, 1e-3)
+		require.Equal(t, float32(6), stat.Jitter)
+		require.Nil(t, stat.BufferDelay)
+		require.Nil(t, stat.RoundTripTime)
+		require.Equal(t, int32(2000), *stat.Interval)
+		require.Equal(t, float32(25), *stat.VideoConfig.FrameRate)
+		require.Equal(t, int32(1), *stat.VideoConfig.FreezeCount)
+		require.Equal(t, int32(400), *stat.VideoConfig.TotalFreezesDuration)
+		require.Equal(t, int32(1280), *stat.VideoConfig.Width)
+		// the caller's config is not modified
+		require.Nil(t, videoConfig.FrameRate)
+
+		// the next window starts at the end of the previous one
+		_, ok = accumulator.Add(Counters{Timestamp: 3000, PacketsReceived: 300, VideoConfig: videoConfig})
+		require.False(t, ok)
+	}
+	{
+		// 32 bits counters wrapping around
+		accumulator := &Accumulator{WrapBits: 32}
+		accumulator.Add(Counters{Timestamp: 0, PacketsReceived: 1<<32 - 50, BytesReceived: 1<<32 - 1000, AudioConfig: &AudioConfig{}})
+		stat, ok := accumulator.Add(Counters{Timestamp: 1000, PacketsReceived: 50, PacketsLost: 0, BytesReceived: 3000, AudioConfig: &AudioConfig{}})
+		require.True(t, ok)
+		require.InDelta(t, 32000, stat.Bitrate, 1e-3)
+		require.Equal(t, float32(0), stat.PacketLoss)
+	}
+	{
+		// reset of the stream, the new counters are counted from zero
+		accumulator := NewAccumulator(0)
+		accumulator.Add(Counters{Timestamp: 0, PacketsReceived: 5000, PacketsLost: 100, BytesReceived: 500000, AudioConfig: &AudioConfig{}})
+		stat, ok := accumulator.Add(Counters{Timestamp: 1000, PacketsReceived: 40, PacketsLost: 10, BytesReceived: 4000, AudioConfig: &AudioConfig{}})
+		require.True(t, ok)
+		require.InDelta(t, 32000, stat.Bitrate, 1e-3)
+		require.InDelta(t, 20, stat.PacketLoss, 1e-4)
+
+		// without wraparound, a drop is always a reset
+		accumulator.Add(Counters{Timestamp: 2000, PacketsReceived: 100, BytesReceived: 1 << 40, AudioConfig: &AudioConfig{}})
+		stat, _ = accumulator.Add(Counters{Timestamp: 3000, PacketsReceived: 150, BytesReceived: 2000, AudioConfig: &AudioConfig{}})
+		require.InDelta(t, 16000, stat.Bitrate, 1e-3)
+	}
+	{
+		// duplicates lowering the lost counter
+		accumulator := NewAccumulator(0)
+		accumulator.Add(Counters{Timestamp: 0, PacketsReceived: 100, PacketsLost: 10, AudioConfig: &AudioConfig{}})
+		stat, _ := accumulator.Add(Counters{Timestamp: 1000, PacketsReceived: 150, PacketsLost: 8, AudioConfig: &AudioConfig{}})
+		require.Equal(t, float32(0), stat.PacketLoss)
+	}
+}
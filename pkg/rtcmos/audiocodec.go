@@ -0,0 +1,126 @@
+package rtcmos
+
+import (
+	"math"
+	"strings"
+)
+
+// AudioCodec identifies the audio codec, matched case-insensitively
+type AudioCodec string
+
+const (
+	// AudioCodecOpus: opus, used by default
+	AudioCodecOpus AudioCodec = "opus"
+	// AudioCodecPCMU: G.711 µ-law
+	AudioCodecPCMU AudioCodec = "pcmu"
+	// AudioCodecPCMA: G.711 A-law
+	AudioCodecPCMA AudioCodec = "pcma"
+	// AudioCodecG722: G.722 wideband
+	AudioCodecG722 AudioCodec = "g722"
+	// AudioCodecILBC: internet Low Bitrate Codec
+	AudioCodecILBC AudioCodec = "ilbc"
+	// AudioCodecAMRWB: AMR-WB (G.722.2)
+	AudioCodecAMRWB AudioCodec = "amr-wb"
+	// AudioCodecAAC: AAC-LD
+	AudioCodecAAC AudioCodec = "aac"
+)
+
+// codecImpairment contains the E-model parameters of a codec mode
+type codecImpairment struct {
+	// bitrate: nominal bitrate of the mode (bps)
+	bitrate float32
+	// narrowband, wideband, fullband: equipment impairment factor Ie on the scale of each bandwidth
+	narrowband float64
+	wideband   float64
+	fullband   float64
+	// bpl: packet loss robustness factor with the packet loss concealment of the codec
+	bpl float64
+}
+
+// ie - equipment impairment factor on the scale of the bandwidth
+func (c codecImpairment) ie(bandwidth AudioBandwidth) float64 {
+	switch bandwidth {
+	case AudioBandwidthWideband:
+		return c.wideband
+	case AudioBandwidthFullband:
+		return c.fullband
+	default:
+		return c.narrowband
+	}
+}
+
+// codecImpairments - Ie and Bpl per codec and bitrate mode.
+//
+// Narrowband values follow ITU-T G.113 Appendix I and wideband values ITU-T G.113 Appendix IV where available.
+// Other values are estimates: a narrowband codec loses 36 on the wideband scale (129 - 93 for G.711)
+// and a wideband codec loses 19 on the fullband scale (148 - 129).
+// Opus is not listed, its Ie follows a curve of the bitrate.
+var codecImpairments = map[AudioCodec][]codecImpairment{
+	AudioCodecPCMU: {
+		{bitrate: 64000, narrowband: 0, wideband: 36, fullband: 55, bpl: 25.1},
+	},
+	AudioCodecPCMA: {
+		{bitrate: 64000, narrowband: 0, wideband: 36, fullband: 55, bpl: 25.1},
+	},
+	AudioCodecG722: {
+		{bitrate: 48000, narrowband: 0, wideband: 31, fullband: 50, bpl: 13},
+		{bitrate: 56000, narrowband: 0, wideband: 20, fullband: 39, bpl: 13},
+		{bitrate: 64000, narrowband: 0, wideband: 13, fullband: 32, bpl: 13},
+	},
+	AudioCodecILBC: {
+		{bitrate: 13330, narrowband: 15, wideband: 51, fullband: 70, bpl: 32},
+		{bitrate: 15200, narrowband: 11, wideband: 47, fullband: 66, bpl: 32},
+	},
+	AudioCodecAMRWB: {
+		{bitrate: 6600, narrowband: 10, wideband: 39, fullband: 58, bpl: 10},
+		{bitrate: 8850, narrowband: 0, wideband: 25, fullband: 44, bpl: 10},
+		{bitrate: 12650, narrowband: 0, wideband: 11, fullband: 30, bpl: 10},
+		{bitrate: 14250, narrowband: 0, wideband: 10, fullband: 29, bpl: 10},
+		{bitrate: 15850, narrowband: 0, wideband: 7, fullband: 26, bpl: 10},
+		{bitrate: 18250, narrowband: 0, wideband: 5, fullband: 24, bpl: 10},
+		{bitrate: 19850, narrowband: 0, wideband: 4, fullband: 23, bpl: 10},
+		{bitrate: 23050, narrowband: 0, wideband: 1, fullband: 20, bpl: 10},
+		{bitrate: 23850, narrowband: 0, wideband: 1, fullband: 20, bpl: 10},
+	},
+	AudioCodecAAC: {
+		{bitrate: 48000, narrowband: 0, wideband: 0, fullband: 12, bpl: 6},
+		{bitrate: 64000, narrowband: 0, wideband: 0, fullband: 6, bpl: 6},
+		{bitrate: 96000, narrowband: 0, wideband: 0, fullband: 2, bpl: 6},
+		{bitrate: 128000, narrowband: 0, wideband: 0, fullband: 0, bpl: 6},
+	},
+}
+
+// codecBitrates - bitrates of the modes of the codec in increasing order, false when the codec is not in the table
+func codecBitrates(codec AudioCodec) ([]float32, bool) {
+	modes, ok := codecImpairments[AudioCodec(strings.ToLower(string(codec)))]
+	if !ok {
+		return nil, false
+	}
+	bitrates := make([]float32, 0, len(modes))
+	for _, mode := range modes {
+		bitrates = append(bitrates, mode.bitrate)
+	}
+	return bitrates, true
+}
+
+// lookupCodecImpairment - returns the mode of the codec closest to the bitrate,
+// the highest bitrate mode when bitrate is unknown, false when the codec is not in the table
+func lookupCodecImpairment(codec AudioCodec, bitrate float32) (codecImpairment, bool) {
+	modes, ok := codecImpairments[AudioCodec(strings.ToLower(string(codec)))]
+	if !ok {
+		return codecImpairment{}, false
+	}
+
+	mode := modes[len(modes)-1]
+	if bitrate > 0 {
+		distance := math.Inf(1)
+		for _, m := range modes {
+			d := math.Abs(math.Log(float64(bitrate) / float64(m.bitrate)))
+			if d < distance {
+				distance = d
+				mode = m
+			}
+		}
+	}
+	return mode, true
+}
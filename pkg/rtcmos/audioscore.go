@@ -4,70 +4,200 @@ import (
 	"math"
 )
 
+// AudioModel selects the E-model variant used by AudioScore
+type AudioModel string
+
+const (
+	// AudioModelSimplified: modified E-model with a fixed R0 and a linear delay impairment, used by default
+	AudioModelSimplified AudioModel = "simplified"
+	// AudioModelG107: E-model as specified in ITU-T G.107
+	AudioModelG107 AudioModel = "g107"
+)
+
+// AudioBandwidth selects the E-model scale, ITU-T G.107 (narrowband), G.107.1 (wideband) or G.107.2 (fullband)
+type AudioBandwidth string
+
+const (
+	// AudioBandwidthNarrowband: 300-3400 Hz, R up to 100, used by default
+	AudioBandwidthNarrowband AudioBandwidth = "narrowband"
+	// AudioBandwidthWideband: 50-7000 Hz, R up to 129
+	AudioBandwidthWideband AudioBandwidth = "wideband"
+	// AudioBandwidthFullband: 20-20000 Hz, R up to 148
+	AudioBandwidthFullband AudioBandwidth = "fullband"
+)
+
+// Scale returns the factor between the R scale of the bandwidth and the narrowband one
+func (b AudioBandwidth) Scale() float64 {
+	switch b {
+	case AudioBandwidthWideband:
+		return 1.29
+	case AudioBandwidthFullband:
+		return 1.48
+	default:
+		return 1
+	}
+}
+
 // AudioConfig is used to specify audio configuration used
 type AudioConfig struct {
+	// Codec: audio codec used, defaults to AudioCodecOpus. Codecs without impairment values are scored with the
+	// opus curve of the bitrate
+	Codec AudioCodec
 	// Fec: flag to pass opus forward error correction status
 	Fec *bool
 	// Dtx: flag to pass opus discontinuous transmission status
 	Dtx *bool
 	// Red: Flag to pass RED (Redundant Encoding) enabled
 	Red *bool
+	// Model: E-model variant to use, defaults to AudioModelSimplified
+	Model AudioModel
+	// Bandwidth: audio bandwidth of the stream, defaults to AudioBandwidthNarrowband
+	Bandwidth AudioBandwidth
+	// EModel: transmission parameters of the G.107 model, defaults to DefaultEModelParams
+	EModel *EModelParams
 }
 
 // AudioScore - MOS calculation based on E-Model algorithm
 func AudioScore(input Stat) Scores {
-	stat := normalizeAudioStat(input)
-	const R0 = 100
-	// Assume 20 packetization delay
-	delay := float64(20 + *stat.BufferDelay + *stat.RoundTripTime/2)
+	return AudioScoreWith(input, nil)
+}
+
+// AudioScoreWith - AudioScore with the given coefficients, DefaultCoefficients when nil
+func AudioScoreWith(input Stat, coefficients *Coefficients) Scores {
+	if input.AudioConfig == nil {
+		return Scores{}
+	}
+	coefficients = coefficients.orDefault()
+	c := &coefficients.Audio
+	stat := normalizeAudioStat(input, coefficients)
+	if stat.AudioConfig.Model == AudioModelG107 {
+		return g107AudioScore(stat, c)
+	}
+
+	bandwidth := stat.AudioConfig.Bandwidth
+	// impairments are expressed on the narrowband scale and stretched to the scale of the bandwidth
+	R0 := 100 * bandwidth.Scale()
+	delay := audioDelay(stat, c)
 	pl := float64(stat.PacketLoss)
 
+	Ie, Bpl := audioEquipmentImpairment(stat, c)
+
+	// loss is more impairing when it comes in bursts (ITU-T G.107 BurstR)
+	Ipl := Ie
+	if pl > 0 {
+		Ipl = Ie + (R0-Ie)*(pl/(pl/stat.Burst.burstR(pl)+Bpl))
+	}
+
+	delayFactor := float64(0)
+	if delay > c.DelayThreshold {
+		delayFactor = c.DelayFactorAboveThreshold * (delay - c.DelayThreshold)
+	}
+	Id := (delay*c.DelayFactor + delayFactor) * bandwidth.Scale()
+
+	return audioScores(EModelImpairments{Ro: R0, Id: Id, IeEff: Ipl}, bandwidth)
+}
+
+// g107AudioScore - MOS calculation based on the ITU-T G.107 E-model
+func g107AudioScore(stat Stat, c *AudioCoefficients) Scores {
+	delay := audioDelay(stat, c)
+	Ie, Bpl := audioEquipmentImpairment(stat, c)
+
+	impairments := stat.AudioConfig.EModel.impairments(stat.AudioConfig.Bandwidth, eModelConditions{
+		T:   delay,
+		Ta:  delay,
+		Tr:  2 * delay,
+		Ie:  Ie,
+		Bpl: Bpl,
+		Ppl: float64(stat.PacketLoss),
+		// loss is more impairing when it comes in bursts
+		BurstR: stat.Burst.burstR(float64(stat.PacketLoss)),
+	})
+
+	return audioScores(impairments, stat.AudioConfig.Bandwidth)
+}
+
+// audioScores - conversational and listening quality of the impairments, the listening quality ignores delay
+func audioScores(impairments EModelImpairments, bandwidth AudioBandwidth) Scores {
+	R := clamp(impairments.R(), 0, 100*bandwidth.Scale())
+	listening := impairments
+	listening.Id = 0
+	return Scores{
+		AudioScore:          mosFromR(R, bandwidth),
+		AudioListeningScore: mosFromR(clamp(listening.R(), 0, 100*bandwidth.Scale()), bandwidth),
+		RFactor:             R,
+		Impairments:         impairments,
+	}
+}
+
+// audioDelay - mouth to ear delay
+func audioDelay(stat Stat, c *AudioCoefficients) float64 {
+	return c.PacketizationDelay + float64(*stat.BufferDelay+*stat.RoundTripTime/2)
+}
+
+// audioEquipmentImpairment - returns the equipment impairment factor Ie on the scale of the audio bandwidth
+// and the packet loss robustness factor Bpl
+func audioEquipmentImpairment(stat Stat, c *AudioCoefficients) (float64, float64) {
 	audioConfig := stat.AudioConfig
 
-	// Ignore audio bitrate in dtx mode
-	var Ie float64
-	if *audioConfig.Dtx {
-		Ie = 8
+	var Ie, Bpl float64
+	if mode, ok := lookupCodecImpairment(audioConfig.Codec, stat.Bitrate); ok {
+		Ie = mode.ie(audioConfig.Bandwidth)
+		Bpl = mode.bpl
 	} else {
-		if stat.Bitrate > 0 {
-			Ie = clamp(55-4.6*math.Log(float64(stat.Bitrate)), 0, 30)
+		c = c.forConfig(audioConfig)
+		// Ignore audio bitrate in dtx mode
+		if *audioConfig.Dtx {
+			Ie = c.IeDtx
 		} else {
-			Ie = 6
+			if stat.Bitrate > 0 {
+				Ie = clamp(c.IeIntercept-c.IeSlope*math.Log(float64(stat.Bitrate)), 0, c.IeMax)
+			} else {
+				Ie = c.IeUnknownBitrate
+			}
 		}
-	}
+		Ie *= audioConfig.Bandwidth.Scale()
 
-	Bpl := float64(10)
-	if *audioConfig.Fec {
-		Bpl = 20
+		Bpl = c.Bpl
+		if *audioConfig.Fec {
+			Bpl = c.BplFec
+		}
 	}
+
 	if *audioConfig.Red {
-		// with 2 packets redundancy, should be able to absorb 2 out of every 3 packets lost without quality impact,
-		// set this value so that even significant loss rate (i. e. something like 10%) does not affect score a lot.
-		Bpl = 90
+		Bpl = c.BplRed
 	}
 
-	Ipl := Ie + (100-Ie)*(pl/(pl+Bpl))
+	return Ie, Bpl
+}
 
-	delayFactor := float64(0)
-	if delay > 150 {
-		delayFactor = 0.1 * (delay - 150)
+// mosFromR - converts the R rating factor to MOS
+//
+// R is brought back to the narrowband scale (ITU-T G.107.1 / G.107.2) and converted with ITU-T G.107 Annex B.
+// To keep wider bandwidths distinguishable from narrowband, the resulting MOS is then stretched to the ceiling
+// of the bandwidth, growing from 4.5 for narrowband up to 4.75 for fullband which matches the saturation of
+// ITU-T P.863 in fullband mode.
+func mosFromR(R float64, bandwidth AudioBandwidth) float64 {
+	R = R / bandwidth.Scale()
+	var MOS float64
+	switch {
+	case R <= 0:
+		MOS = 1
+	case R >= 100:
+		MOS = 4.5
+	default:
+		MOS = 1 + 0.035*R + (R*(R-60)*(100-R)*7)/1000000
 	}
-	Id := delay*0.03 + delayFactor
-
-	R := clamp(R0-Ipl-Id, 0, 100)
-	MOS := 1 + 0.035*R + (R*(R-60)*(100-R)*7)/1000000
-
-	return Scores{AudioScore: clamp(math.Round(MOS*100)/100, 1, 5)}
+	ceiling := 4.5 + 0.25*(bandwidth.Scale()-1)/0.48
+	MOS = 1 + (MOS-1)*(ceiling-1)/3.5
+	return clamp(math.Round(MOS*100)/100, 1, 5)
 }
 
-func normalizeAudioStat(input Stat) Stat {
-	if input.RoundTripTime == nil {
-		input.RoundTripTime = int32Ptr(DefaultRoundTripTime)
-	}
+// normalizeAudioStat - fills the defaults on a copy, the caller's config is never modified
+func normalizeAudioStat(input Stat, coefficients *Coefficients) Stat {
+	input = normalizeNetworkStat(input, coefficients)
 
-	if input.BufferDelay == nil {
-		input.BufferDelay = int32Ptr(DefaultBufferDelay)
-	}
+	audioConfig := *input.AudioConfig
+	input.AudioConfig = &audioConfig
 
 	if input.AudioConfig.Fec == nil {
 		input.AudioConfig.Fec = boolPtr(true)
@@ -78,6 +208,18 @@ func normalizeAudioStat(input Stat) Stat {
 	if input.AudioConfig.Red == nil {
 		input.AudioConfig.Red = boolPtr(false)
 	}
+	if input.AudioConfig.Model == "" {
+		input.AudioConfig.Model = AudioModelSimplified
+	}
+	if input.AudioConfig.Codec == "" {
+		input.AudioConfig.Codec = AudioCodecOpus
+	}
+	if input.AudioConfig.Bandwidth == "" {
+		input.AudioConfig.Bandwidth = AudioBandwidthNarrowband
+	}
+	if input.AudioConfig.EModel == nil {
+		input.AudioConfig.EModel = DefaultEModelParams()
+	}
 
 	return input
 }
@@ -0,0 +1,102 @@
+package rtcmos
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrAudiovisualUnsupported is returned when the scorer does not implement CombinedScorer
+var ErrAudiovisualUnsupported = errors.New("scorer does not score audio and video pairs")
+
+// CombinedScorer is implemented by scorers able to score an audio and video pair as a whole
+type CombinedScorer interface {
+	// AudiovisualScore - scores a valid audio stat and a valid video stat of the same participant
+	AudiovisualScore(audio, video Stat) Scores
+}
+
+// AudiovisualScore validates and scores an audio and video pair with the default scorer, see AudiovisualScoreWith
+func AudiovisualScore(audio, video Stat) (Scores, error) {
+	_, scorer := DefaultScorer()
+	return AudiovisualScoreWith(scorer, audio, video)
+}
+
+// AudiovisualScoreWith validates and scores an audio and video pair with the given scorer, returns the audio
+// scores, the video score and the overall AudiovisualScore.
+//
+// Returns a *ValidationError when a stat is invalid or of the wrong kind, ErrAudiovisualUnsupported when the scorer
+// does not implement CombinedScorer.
+func AudiovisualScoreWith(scorer Scorer, audio, video Stat) (Scores, error) {
+	if audio.AudioConfig == nil {
+		return Scores{}, &ValidationError{Field: "AudioConfig", Reason: "audio stat has no audio config"}
+	}
+	if video.VideoConfig == nil {
+		return Scores{}, &ValidationError{Field: "VideoConfig", Reason: "video stat has no video config"}
+	}
+	if err := audio.Validate(); err != nil {
+		return Scores{}, err
+	}
+	if err := video.Validate(); err != nil {
+		return Scores{}, err
+	}
+
+	combined, ok := scorer.(CombinedScorer)
+	if !ok {
+		return Scores{}, ErrAudiovisualUnsupported
+	}
+	return combined.AudiovisualScore(audio, video), nil
+}
+
+func (s coefficientsScorer) AudiovisualScore(audio, video Stat) Scores {
+	return audiovisualScore(audio, video, s.coefficients)
+}
+
+// audiovisualScore - multimedia integration of the audio and video scores with a lip-sync penalty
+func audiovisualScore(audio, video Stat, coefficients *Coefficients) Scores {
+	coefficients = coefficients.orDefault()
+	c := &coefficients.Audiovisual
+
+	scores := AudioScoreWith(audio, coefficients)
+	scores.VideoScore = VideoScoreWith(video, coefficients).VideoScore
+
+	MOSa, MOSv := scores.AudioScore, scores.VideoScore
+	score := c.Intercept + c.AudioFactor*MOSa + c.VideoFactor*MOSv + c.ProductFactor*MOSa*MOSv
+	// the delays of the tracks say nothing about their synchronization, only a measured offset is penalized
+	if offset, ok := syncOffset(audio, video); ok {
+		scores.SyncOffset = offset
+		score -= syncPenalty(offset, c)
+	}
+
+	scores.AudiovisualScore = clamp(math.Round(score*100)/100, 1, 5)
+	return scores
+}
+
+// syncOffset - SyncOffset of the audio stat, of the video stat otherwise, false when the offset was not measured
+func syncOffset(audio, video Stat) (float64, bool) {
+	if audio.SyncOffset != nil {
+		return float64(*audio.SyncOffset), true
+	}
+	if video.SyncOffset != nil {
+		return float64(*video.SyncOffset), true
+	}
+	return 0, false
+}
+
+// syncPenalty - lip-sync penalty following ITU-R BT.1359: none up to the detectability threshold, growing linearly
+// up to SyncAcceptabilityPenalty at the acceptability threshold and by SyncFactor per ms beyond.
+// Audio leading the video is penalized earlier than audio lagging, as sound never comes before the image in nature.
+func syncPenalty(offset float64, c *AudiovisualCoefficients) float64 {
+	detectability, acceptability := c.SyncLeadDetectability, c.SyncLeadAcceptability
+	if offset < 0 {
+		offset = -offset
+		detectability, acceptability = c.SyncLagDetectability, c.SyncLagAcceptability
+	}
+
+	switch {
+	case offset <= detectability:
+		return 0
+	case offset <= acceptability:
+		return c.SyncAcceptabilityPenalty * (offset - detectability) / (acceptability - detectability)
+	default:
+		return c.SyncAcceptabilityPenalty + c.SyncFactor*(offset-acceptability)
+	}
+}
@@ -0,0 +1,133 @@
+package rtcmos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// noCombinedScorer - a scorer not implementing CombinedScorer
+type noCombinedScorer struct {
+	Scorer
+}
+
+func TestAudiovisualScore(t *testing.T) {
+	audio := Stat{
+		Bitrate:       64000,
+		RoundTripTime: int32Ptr(100),
+		BufferDelay:   int32Ptr(40),
+		AudioConfig:   &AudioConfig{},
+	}
+	video := Stat{
+		Bitrate:       1700000,
+		RoundTripTime: int32Ptr(100),
+		BufferDelay:   int32Ptr(60),
+		VideoConfig:   &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720)},
+	}
+
+	{
+		// combination of the audio and video scores, tracks in sync
+		scores, err := AudiovisualScore(audio, video)
+		require.NoError(t, err)
+		MOSa, MOSv := AudioScore(audio).AudioScore, VideoScore(video).VideoScore
+		require.Equal(t, MOSa, scores.AudioScore)
+		require.Equal(t, AudioScore(audio).RFactor, scores.RFactor)
+		require.Equal(t, MOSv, scores.VideoScore)
+		expected := 1.12 + 0.007*MOSa + 0.24*MOSv + 0.088*MOSa*MOSv
+		require.InDelta(t, expected, scores.AudiovisualScore, 0.005)
+
+		// video quality dominates: the overall score drops more per MOS of video lost than per MOS of audio lost
+		worseAudio := audio
+		worseAudio.PacketLoss = 5
+		worseVideo := video
+		worseVideo.Bitrate = 600000
+		audioDrop := (scores.AudiovisualScore - scoresOf(t, worseAudio, video).AudiovisualScore) /
+			(MOSa - AudioScore(worseAudio).AudioScore)
+		videoDrop := (scores.AudiovisualScore - scoresOf(t, audio, worseVideo).AudiovisualScore) /
+			(MOSv - VideoScore(worseVideo).VideoScore)
+		require.Greater(t, audioDrop, 0.0)
+		require.Greater(t, videoDrop, audioDrop)
+	}
+
+	{
+		// different buffer delays of the tracks are not an offset, no penalty without a measured offset
+		late := video
+		late.BufferDelay = int32Ptr(200)
+		audio := audio
+		audio.BufferDelay = int32Ptr(40)
+		scores := scoresOf(t, audio, late)
+		require.Equal(t, 0.0, scores.SyncOffset)
+		expected := 1.12 + 0.007*scores.AudioScore + 0.24*scores.VideoScore + 0.088*scores.AudioScore*scores.VideoScore
+		require.InDelta(t, expected, scores.AudiovisualScore, 0.005)
+	}
+
+	{
+		// explicit offset, audio leading is penalized earlier than audio lagging (ITU-R BT.1359)
+		inSync := scoresOf(t, audio, video)
+		require.Equal(t, 0.0, inSync.SyncOffset)
+		withOffset := func(offset int32) Scores {
+			synced := audio
+			synced.SyncOffset = int32Ptr(offset)
+			return scoresOf(t, synced, video)
+		}
+		require.Equal(t, inSync.AudiovisualScore, withOffset(45).AudiovisualScore)
+		require.Equal(t, inSync.AudiovisualScore, withOffset(-125).AudiovisualScore)
+		require.Less(t, withOffset(80).AudiovisualScore, withOffset(-80).AudiovisualScore)
+		require.Equal(t, inSync.AudiovisualScore, withOffset(-80).AudiovisualScore)
+		require.InDelta(t, inSync.AudiovisualScore-0.5, withOffset(90).AudiovisualScore, 0.011)
+		require.InDelta(t, inSync.AudiovisualScore-0.5, withOffset(-185).AudiovisualScore, 0.011)
+		require.InDelta(t, inSync.AudiovisualScore-0.25, withOffset(-155).AudiovisualScore, 0.011)
+
+		// the offset of the video stat is used when the audio one is not set
+		synced := video
+		synced.SyncOffset = int32Ptr(-200)
+		synced.BufferDelay = int32Ptr(240)
+		scores := scoresOf(t, audio, synced)
+		require.Equal(t, -200.0, scores.SyncOffset)
+	}
+
+	{
+		// invalid pairs
+		_, err := AudiovisualScore(video, audio)
+		var validationError *ValidationError
+		require.True(t, errors.As(err, &validationError))
+		require.Equal(t, "AudioConfig", validationError.Field)
+
+		invalid := video
+		invalid.PacketLoss = 101
+		_, err = AudiovisualScore(audio, invalid)
+		require.True(t, errors.As(err, &validationError))
+		require.Equal(t, "PacketLoss", validationError.Field)
+
+		_, err = AudiovisualScoreWith(noCombinedScorer{NewScorer(nil)}, audio, video)
+		require.True(t, errors.Is(err, ErrAudiovisualUnsupported))
+	}
+
+	{
+		// coefficients
+		coefficients, err := ParseCoefficientsYAML([]byte("audiovisual:\n  sync_factor: 0\n"))
+		require.NoError(t, err)
+		late := video
+		late.SyncOffset = int32Ptr(1000)
+		scores, err := AudiovisualScoreWith(NewScorer(coefficients), audio, late)
+		require.NoError(t, err)
+		require.Greater(t, scores.AudiovisualScore, scoresOf(t, audio, late).AudiovisualScore)
+
+		_, err = ParseCoefficientsJSON([]byte(`{"audiovisual": {"video_factor": -1}}`))
+		var validationError *ValidationError
+		require.True(t, errors.As(err, &validationError))
+		require.Equal(t, "Audiovisual.VideoFactor", validationError.Field)
+
+		_, err = ParseCoefficientsJSON([]byte(`{"audiovisual": {"sync_lag_acceptability": 100}}`))
+		require.True(t, errors.As(err, &validationError))
+		require.Equal(t, "Audiovisual.SyncLagAcceptability", validationError.Field)
+	}
+}
+
+// scoresOf - audiovisual scores of a valid pair with the default scorer
+func scoresOf(t *testing.T, audio, video Stat) Scores {
+	scores, err := AudiovisualScore(audio, video)
+	require.NoError(t, err)
+	return scores
+}
@@ -0,0 +1,51 @@
+package rtcmos
+
+// BurstStat describes how packet loss is distributed over time, as reported by RFC 3611 VoIP metrics
+type BurstStat struct {
+	// MeanBurstLength: average number of consecutive packets lost
+	MeanBurstLength float32
+	// BurstDensity: percentage of packets lost within bursts
+	BurstDensity float32
+	// GapDensity: percentage of packets lost within gaps
+	GapDensity float32
+}
+
+// meanBurstLength - average number of consecutive packets lost,
+// estimated from burst and gap densities when not reported, 0 if unknown
+func (b *BurstStat) meanBurstLength(packetLoss float64) float64 {
+	if b == nil {
+		return 0
+	}
+	if b.MeanBurstLength > 0 {
+		return float64(b.MeanBurstLength)
+	}
+
+	burstDensity := float64(b.BurstDensity) / 100
+	gapDensity := float64(b.GapDensity) / 100
+	pl := packetLoss / 100
+	if burstDensity <= gapDensity || burstDensity >= 1 || pl <= gapDensity || pl >= burstDensity {
+		return 0
+	}
+
+	// share of packets in the burst state so that packet loss averages the densities
+	inBurst := (pl - gapDensity) / (burstDensity - gapDensity)
+	// losses within bursts are random, losses within gaps are isolated
+	burstRuns := inBurst * burstDensity * (1 - burstDensity)
+	gapRuns := (1 - inBurst) * gapDensity
+	return pl / (burstRuns + gapRuns)
+}
+
+// burstR - burst ratio of ITU-T G.107 for a two-state (Gilbert) loss model,
+// 1 for random loss or when burst statistics are unknown
+func (b *BurstStat) burstR(packetLoss float64) float64 {
+	length := b.meanBurstLength(packetLoss)
+	pl := packetLoss / 100
+	if length <= 0 || pl <= 0 || pl >= 1 {
+		return 1
+	}
+
+	// q: probability to leave the loss state, p: probability to enter it
+	q := 1 / length
+	p := pl * q / (1 - pl)
+	return 1 / (p + q)
+}
@@ -0,0 +1,244 @@
+package rtcmos
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// DefaultPrimacyWeight, DefaultPrimacyDuration: extra weight of the start of the call,
+	// decaying over the duration (ms)
+	DefaultPrimacyWeight   = 0.5
+	DefaultPrimacyDuration = 10000
+	// DefaultRecencyWeight, DefaultRecencyDuration: extra weight of the end of the call, decaying over the duration (ms)
+	DefaultRecencyWeight   = 1
+	DefaultRecencyDuration = 15000
+	// DefaultWorstSegmentDuration: duration of the worst segment (ms)
+	DefaultWorstSegmentDuration = 10000
+	// DefaultWorstSegmentWeight: share of the gap between the weighted mean and the worst segment taken off the score
+	DefaultWorstSegmentWeight = 0.3
+)
+
+// CallAggregator integrates the interval scores of a participant into a call-level score.
+//
+// A plain average misrepresents the perceived quality of a call: the end of the call (recency) and, to a lesser
+// extent, its start (primacy) weigh more on the overall opinion, and a bad segment is remembered even when the rest
+// of the call is fine. In the spirit of ITU-T P.1203.3, intervals are weighted by
+//
+//	1 + PrimacyWeight * exp(-t / PrimacyDuration) + RecencyWeight * exp(-(end - t) / RecencyDuration)
+//
+// and the weighted mean is pulled towards the mean score of the worst segment by WorstSegmentWeight.
+//
+// Every interval of the call is kept, the history grows with the call: an 8 hour call with 1 second intervals holds
+// 28800 intervals of 24 bytes, and Score sorts them in O(n log n).
+type CallAggregator struct {
+	// Thresholds: levels used for the time spent below thresholds
+	Thresholds QualityThresholds
+	// PrimacyWeight, PrimacyDuration: extra weight of the start of the call, decaying over the duration (ms)
+	PrimacyWeight   float64
+	PrimacyDuration float64
+	// RecencyWeight, RecencyDuration: extra weight of the end of the call, decaying over the duration (ms)
+	RecencyWeight   float64
+	RecencyDuration float64
+	// WorstSegmentDuration: duration of the worst segment (ms), the whole call when longer than the call
+	WorstSegmentDuration float64
+	// WorstSegmentWeight: share (0 - 1) of the gap between the weighted mean and the worst segment taken off the score
+	WorstSegmentWeight float64
+
+	intervals []callInterval
+}
+
+// callInterval - score of an interval of the call
+type callInterval struct {
+	start    float64
+	duration float64
+	score    float64
+}
+
+// CallScore contains the call-level scores
+type CallScore struct {
+	// MOS: integrated score of the call
+	MOS float64
+	// Mean: time weighted mean of the interval scores, without recency, primacy or worst segment
+	Mean float64
+	// Worst: mean score of the worst segment
+	Worst float64
+	// Duration: time covered by the intervals (ms)
+	Duration float64
+	// TimeBelowGood, TimeBelowPoor: time spent below the good and poor thresholds (ms)
+	TimeBelowGood float64
+	TimeBelowPoor float64
+}
+
+// NewCallAggregator returns a CallAggregator with the thresholds and the default weights
+func NewCallAggregator(thresholds QualityThresholds) *CallAggregator {
+	return &CallAggregator{
+		Thresholds:           thresholds,
+		PrimacyWeight:        DefaultPrimacyWeight,
+		PrimacyDuration:      DefaultPrimacyDuration,
+		RecencyWeight:        DefaultRecencyWeight,
+		RecencyDuration:      DefaultRecencyDuration,
+		WorstSegmentDuration: DefaultWorstSegmentDuration,
+		WorstSegmentWeight:   DefaultWorstSegmentWeight,
+	}
+}
+
+// Add ingests the score of the interval starting at the timestamp (ms) and lasting the duration (ms), intervals may
+// come in any order. A score of 0 (no media) counts as the minimum score, intervals without a positive duration are
+// ignored.
+func (a *CallAggregator) Add(timestamp, duration, score float64) {
+	if duration <= 0 {
+		return
+	}
+	a.intervals = append(a.intervals, callInterval{start: timestamp, duration: duration, score: clamp(score, 1, 5)})
+}
+
+// AddStat ingests the score of the stat of the interval ending at the timestamp (ms) with the default scorer,
+// the stat lasts its Interval (DefaultInterval when not set). An invalid stat counts as no media.
+func (a *CallAggregator) AddStat(timestamp float64, stat Stat) {
+	duration := float64(DefaultInterval)
+	if stat.Interval != nil {
+		duration = float64(*stat.Interval)
+	}
+	result := Evaluate([]Stat{stat})[0]
+	a.Add(timestamp-duration, duration, trackScore(stat, result.Scores))
+}
+
+// Score returns the call-level scores, false before the first interval
+func (a *CallAggregator) Score() (CallScore, bool) {
+	if len(a.intervals) == 0 {
+		return CallScore{}, false
+	}
+
+	intervals := make([]callInterval, len(a.intervals))
+	copy(intervals, a.intervals)
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+	start := intervals[0].start
+	end := start
+	for _, interval := range intervals {
+		end = math.Max(end, interval.start+interval.duration)
+	}
+
+	var score CallScore
+	var sum, weightedSum, weights float64
+	for _, interval := range intervals {
+		score.Duration += interval.duration
+		sum += interval.score * interval.duration
+
+		middle := interval.start + interval.duration/2
+		weight := interval.duration * (1 + decay(a.PrimacyWeight, middle-start, a.PrimacyDuration) +
+			decay(a.RecencyWeight, end-middle, a.RecencyDuration))
+		weightedSum += interval.score * weight
+		weights += weight
+
+		switch a.Thresholds.Classify(interval.score) {
+		case QualityLost:
+			score.TimeBelowPoor += interval.duration
+			score.TimeBelowGood += interval.duration
+		case QualityPoor:
+			score.TimeBelowGood += interval.duration
+		}
+	}
+	score.Mean = sum / score.Duration
+
+	weighted := weightedSum / weights
+	score.Worst = worstSegment(intervals, start, end, a.WorstSegmentDuration)
+	score.MOS = weighted
+	if score.Worst < weighted {
+		score.MOS = weighted - clamp(a.WorstSegmentWeight, 0, 1)*(weighted-score.Worst)
+	}
+	return score, true
+}
+
+// decay - weight decaying exponentially over the duration, 0 without duration
+func decay(weight, elapsed, duration float64) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return weight * math.Exp(-elapsed/duration)
+}
+
+// worstSegment - lowest time weighted mean score of the windows of the duration starting at each interval.
+//
+// The windows only move forward, so the score and the covered time up to their bounds are read from cumulative
+// breakpoints with two pointers, in O(n log n) for the sort of the breakpoints.
+func worstSegment(intervals []callInterval, start, end, duration float64) float64 {
+	if duration <= 0 || duration > end-start {
+		duration = end - start
+	}
+
+	breakpoints := cumulativeScores(intervals)
+	from, to := breakpointCursor{breakpoints: breakpoints}, breakpointCursor{breakpoints: breakpoints}
+	worst := math.Inf(1)
+	// intervals are sorted by start, so the windows only move forward
+	for _, interval := range intervals {
+		windowStart := math.Min(interval.start, end-duration)
+		sumFrom, coveredFrom := from.at(windowStart)
+		sumTo, coveredTo := to.at(windowStart + duration)
+		if covered := coveredTo - coveredFrom; covered > 0 {
+			worst = math.Min(worst, (sumTo-sumFrom)/covered)
+		}
+	}
+	return worst
+}
+
+// breakpoint - integrals of the scores and of the coverage from the start of the call up to the time,
+// and their slopes after it
+type breakpoint struct {
+	time      float64
+	sum       float64
+	covered   float64
+	scoreRate float64
+	coverRate float64
+}
+
+// cumulativeScores - breakpoints at the bounds of the intervals, overlapping intervals add up
+func cumulativeScores(intervals []callInterval) []breakpoint {
+	type event struct {
+		time, score, cover float64
+	}
+	events := make([]event, 0, 2*len(intervals))
+	for _, interval := range intervals {
+		events = append(events,
+			event{time: interval.start, score: interval.score, cover: 1},
+			event{time: interval.start + interval.duration, score: -interval.score, cover: -1})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].time < events[j].time })
+
+	breakpoints := make([]breakpoint, 0, len(events))
+	var current breakpoint
+	for i, e := range events {
+		if i == 0 {
+			current.time = e.time
+		} else if e.time > current.time {
+			breakpoints = append(breakpoints, current)
+			elapsed := e.time - current.time
+			current = breakpoint{
+				time:      e.time,
+				sum:       current.sum + current.scoreRate*elapsed,
+				covered:   current.covered + current.coverRate*elapsed,
+				scoreRate: current.scoreRate,
+				coverRate: current.coverRate,
+			}
+		}
+		current.scoreRate += e.score
+		current.coverRate += e.cover
+	}
+	return append(breakpoints, current)
+}
+
+// breakpointCursor - reads the integrals at increasing times
+type breakpointCursor struct {
+	breakpoints []breakpoint
+	index       int
+}
+
+// at - integrals of the scores and of the coverage up to the time, which must not decrease between calls
+func (c *breakpointCursor) at(time float64) (float64, float64) {
+	for c.index+1 < len(c.breakpoints) && c.breakpoints[c.index+1].time <= time {
+		c.index++
+	}
+	b := c.breakpoints[c.index]
+	elapsed := math.Max(0, time-b.time)
+	return b.sum + b.scoreRate*elapsed, b.covered + b.coverRate*elapsed
+}
@@ -0,0 +1,127 @@
+package rtcmos
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallAggregator(t *testing.T) {
+	{
+		// no intervals
+		_, ok := NewCallAggregator(DefaultQualityThresholds()).Score()
+		require.False(t, ok)
+	}
+
+	{
+		// constant quality: every score matches the interval scores
+		aggregator := NewCallAggregator(DefaultQualityThresholds())
+		for i := 0; i < 60; i++ {
+			aggregator.Add(float64(i*1000), 1000, 4)
+		}
+		score, ok := aggregator.Score()
+		require.True(t, ok)
+		require.InDelta(t, 4, score.MOS, 1e-9)
+		require.InDelta(t, 4, score.Mean, 1e-9)
+		require.InDelta(t, 4, score.Worst, 1e-9)
+		require.Equal(t, 60000.0, score.Duration)
+		require.Equal(t, 0.0, score.TimeBelowGood)
+	}
+
+	// a minute long call with 10 bad seconds at the start or at the end
+	call := func(badStart float64) CallScore {
+		aggregator := NewCallAggregator(DefaultQualityThresholds())
+		// intervals may come in any order
+		for i := 59; i >= 0; i-- {
+			score := 4.2
+			if float64(i*1000) >= badStart && float64(i*1000) < badStart+10000 {
+				score = 2
+			}
+			aggregator.Add(float64(i*1000), 1000, score)
+		}
+		score, _ := aggregator.Score()
+		return score
+	}
+
+	{
+		// the worst segment pulls the score below the mean
+		early, late, middle := call(0), call(50000), call(25000)
+		require.InDelta(t, (50*4.2+10*2)/60.0, middle.Mean, 1e-9)
+		require.Equal(t, middle.Mean, early.Mean)
+		require.Equal(t, middle.Mean, late.Mean)
+		require.InDelta(t, 2, middle.Worst, 1e-9)
+		require.Less(t, middle.MOS, middle.Mean)
+		require.Equal(t, 10000.0, middle.TimeBelowPoor)
+		require.Equal(t, 10000.0, middle.TimeBelowGood)
+
+		// recency weighs more than primacy, which weighs more than the middle of the call
+		require.Less(t, late.MOS, early.MOS)
+		require.Less(t, early.MOS, middle.MOS)
+	}
+
+	{
+		// plain average without weights
+		aggregator := &CallAggregator{Thresholds: DefaultQualityThresholds()}
+		aggregator.Add(0, 1000, 4.5)
+		aggregator.Add(1000, 3000, 3)
+		aggregator.Add(4000, 0, 1)
+		score, _ := aggregator.Score()
+		require.InDelta(t, (4.5+3*3)/4, score.MOS, 1e-9)
+		require.Equal(t, 3000.0, score.TimeBelowGood)
+		require.Equal(t, 0.0, score.TimeBelowPoor)
+	}
+
+	{
+		// stats, invalid ones count as no media
+		aggregator := NewCallAggregator(DefaultQualityThresholds())
+		stat := Stat{Bitrate: 64000, Interval: int32Ptr(5000), AudioConfig: &AudioConfig{}}
+		aggregator.AddStat(5000, stat)
+		aggregator.AddStat(10000, Stat{PacketLoss: -1, Interval: int32Ptr(5000), AudioConfig: &AudioConfig{}})
+		score, _ := aggregator.Score()
+		require.Equal(t, 10000.0, score.Duration)
+		require.InDelta(t, (AudioScore(stat).AudioScore+1)/2, score.Mean, 1e-9)
+		require.Equal(t, 5000.0, score.TimeBelowPoor)
+	}
+	{
+		// the worst segment matches a direct computation over each window, with gaps and overlaps
+		intervals := []callInterval{
+			{start: 0, duration: 1000, score: 4}, {start: 1000, duration: 2000, score: 2.5},
+			{start: 2500, duration: 1000, score: 1.5}, {start: 6000, duration: 1000, score: 4.5},
+			{start: 7000, duration: 3000, score: 3}, {start: 11000, duration: 1000, score: 1},
+		}
+		for _, duration := range []float64{500, 2000, 3000, 5000, 20000} {
+			worst := math.Inf(1)
+			for _, interval := range intervals {
+				from := math.Min(interval.start, 12000-math.Min(duration, 12000))
+				to := from + math.Min(duration, 12000)
+				var sum, covered float64
+				for _, other := range intervals {
+					if overlap := math.Min(to, other.start+other.duration) - math.Max(from, other.start); overlap > 0 {
+						sum += other.score * overlap
+						covered += overlap
+					}
+				}
+				if covered > 0 {
+					worst = math.Min(worst, sum/covered)
+				}
+			}
+			require.InDelta(t, worst, worstSegment(intervals, 0, 12000, duration), 1e-9)
+		}
+	}
+
+	{
+		// an 8 hour call with 1 second intervals
+		aggregator := NewCallAggregator(DefaultQualityThresholds())
+		for i := 0; i < 8*3600; i++ {
+			score := 4.0
+			if i >= 3600 && i < 3605 {
+				score = 1.5
+			}
+			aggregator.Add(float64(i*1000), 1000, score)
+		}
+		score, _ := aggregator.Score()
+		require.InDelta(t, (5*1.5+5*4)/10, score.Worst, 1e-6)
+		require.Equal(t, 5000.0, score.TimeBelowPoor)
+	}
+}
@@ -0,0 +1,407 @@
+package rtcmos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Coefficients contains the constants of the scoring models, DefaultCoefficients returns the built-in set
+type Coefficients struct {
+	// JitterBufferFactor: jitter buffer delay needed per ms of jitter
+	JitterBufferFactor float64 `json:"jitter_buffer_factor" yaml:"jitter_buffer_factor"`
+	// JitterAllowance: jitter absorbed by the buffer delay without adding delay (ms)
+	JitterAllowance float64 `json:"jitter_allowance" yaml:"jitter_allowance"`
+
+	Audio       AudioCoefficients       `json:"audio" yaml:"audio"`
+	Video       VideoCoefficients       `json:"video" yaml:"video"`
+	Audiovisual AudiovisualCoefficients `json:"audiovisual" yaml:"audiovisual"`
+}
+
+// AudioCoefficients contains the constants of the simplified E-model
+type AudioCoefficients struct {
+	// PacketizationDelay: delay added to the buffer delay and half the round trip time (ms)
+	PacketizationDelay float64 `json:"packetization_delay" yaml:"packetization_delay"`
+	// IeIntercept, IeSlope, IeMax: opus Ie = IeIntercept - IeSlope * ln(bitrate), clamped to [0, IeMax]
+	IeIntercept float64 `json:"ie_intercept" yaml:"ie_intercept"`
+	IeSlope     float64 `json:"ie_slope" yaml:"ie_slope"`
+	IeMax       float64 `json:"ie_max" yaml:"ie_max"`
+	// IeDtx: opus Ie in dtx mode, where the bitrate is not meaningful
+	IeDtx float64 `json:"ie_dtx" yaml:"ie_dtx"`
+	// IeUnknownBitrate: opus Ie when the bitrate is not known
+	IeUnknownBitrate float64 `json:"ie_unknown_bitrate" yaml:"ie_unknown_bitrate"`
+	// Bpl, BplFec, BplRed: opus packet loss robustness without recovery, with fec and with red
+	Bpl    float64 `json:"bpl" yaml:"bpl"`
+	BplFec float64 `json:"bpl_fec" yaml:"bpl_fec"`
+	BplRed float64 `json:"bpl_red" yaml:"bpl_red"`
+	// DelayFactor: Id per ms of mouth to ear delay
+	DelayFactor float64 `json:"delay_factor" yaml:"delay_factor"`
+	// DelayThreshold, DelayFactorAboveThreshold: additional Id per ms of delay above the threshold
+	DelayThreshold            float64 `json:"delay_threshold" yaml:"delay_threshold"`
+	DelayFactorAboveThreshold float64 `json:"delay_factor_above_threshold" yaml:"delay_factor_above_threshold"`
+	// Configurations: opus impairments replacing the ones above for a combination of flags and bandwidth,
+	// e.g. fitted by the calibration, optional
+	Configurations []AudioConfigurationCoefficients `json:"configurations,omitempty" yaml:"configurations,omitempty"`
+}
+
+// AudioConfigurationCoefficients contains the opus impairments of a combination of flags and bandwidth.
+// IeSlope and IeMax of the Ie curve are shared by all the configurations.
+type AudioConfigurationCoefficients struct {
+	Fec bool `json:"fec" yaml:"fec"`
+	Dtx bool `json:"dtx" yaml:"dtx"`
+	Red bool `json:"red" yaml:"red"`
+	// Bandwidth: AudioBandwidthNarrowband when empty
+	Bandwidth AudioBandwidth `json:"bandwidth,omitempty" yaml:"bandwidth,omitempty"`
+	// IeIntercept, IeDtx, IeUnknownBitrate: Ie of the configuration on the narrowband scale
+	IeIntercept      float64 `json:"ie_intercept" yaml:"ie_intercept"`
+	IeDtx            float64 `json:"ie_dtx" yaml:"ie_dtx"`
+	IeUnknownBitrate float64 `json:"ie_unknown_bitrate" yaml:"ie_unknown_bitrate"`
+	// Bpl: packet loss robustness of the configuration, replacing Bpl, BplFec or BplRed
+	Bpl float64 `json:"bpl" yaml:"bpl"`
+}
+
+// VideoCoefficients contains the constants of the video logarithmic regression
+type VideoCoefficients struct {
+	// BPPPFSlope, BPPPFIntercept: base score = BPPPFSlope * ln(bPPPF) + BPPPFIntercept
+	BPPPFSlope     float64 `json:"bpppf_slope" yaml:"bpppf_slope"`
+	BPPPFIntercept float64 `json:"bpppf_intercept" yaml:"bpppf_intercept"`
+	// FrameRateFactor: penalty per ln(expected frame rate / frame rate)
+	FrameRateFactor float64 `json:"frame_rate_factor" yaml:"frame_rate_factor"`
+	// DelayFactor: penalty per ms of delay
+	DelayFactor float64 `json:"delay_factor" yaml:"delay_factor"`
+	// CodecFactors: bitrate efficiency of codecs compared to vp8/h.264, keyed by lower case codec name
+	CodecFactors map[string]float64 `json:"codec_factors" yaml:"codec_factors"`
+	// Bpl: residual loss (in percent) that halves the quality above the minimum score
+	Bpl float64 `json:"bpl" yaml:"bpl"`
+	// FecRecovery: share of the lost packets FlexFEC / ULPFEC is able to repair
+	FecRecovery float64 `json:"fec_recovery" yaml:"fec_recovery"`
+	// NackMaxRoundTripTime: round trip time at which NACK retransmissions no longer arrive in time (ms)
+	NackMaxRoundTripTime float64 `json:"nack_max_round_trip_time" yaml:"nack_max_round_trip_time"`
+	// FreezeDurationWeight: impairment per share of the active time spent frozen
+	FreezeDurationWeight float64 `json:"freeze_duration_weight" yaml:"freeze_duration_weight"`
+	// FreezeCountWeight: impairment per freeze per minute
+	FreezeCountWeight float64 `json:"freeze_count_weight" yaml:"freeze_count_weight"`
+}
+
+// AudiovisualCoefficients contains the constants of the multimedia integration of the audio and video scores
+type AudiovisualCoefficients struct {
+	// Intercept, AudioFactor, VideoFactor, ProductFactor:
+	// score = Intercept + AudioFactor * MOSa + VideoFactor * MOSv + ProductFactor * MOSa * MOSv
+	Intercept     float64 `json:"intercept" yaml:"intercept"`
+	AudioFactor   float64 `json:"audio_factor" yaml:"audio_factor"`
+	VideoFactor   float64 `json:"video_factor" yaml:"video_factor"`
+	ProductFactor float64 `json:"product_factor" yaml:"product_factor"`
+	// SyncLeadDetectability, SyncLagDetectability: audio / video offset (ms) below which the skew is not noticed,
+	// when the audio leads and lags the video
+	SyncLeadDetectability float64 `json:"sync_lead_detectability" yaml:"sync_lead_detectability"`
+	SyncLagDetectability  float64 `json:"sync_lag_detectability" yaml:"sync_lag_detectability"`
+	// SyncLeadAcceptability, SyncLagAcceptability: audio / video offset (ms) at which the skew becomes unacceptable,
+	// when the audio leads and lags the video
+	SyncLeadAcceptability float64 `json:"sync_lead_acceptability" yaml:"sync_lead_acceptability"`
+	SyncLagAcceptability  float64 `json:"sync_lag_acceptability" yaml:"sync_lag_acceptability"`
+	// SyncAcceptabilityPenalty: penalty at the acceptability threshold, growing linearly from the detectability one
+	SyncAcceptabilityPenalty float64 `json:"sync_acceptability_penalty" yaml:"sync_acceptability_penalty"`
+	// SyncFactor: additional penalty per ms of offset beyond the acceptability threshold
+	SyncFactor float64 `json:"sync_factor" yaml:"sync_factor"`
+}
+
+// DefaultCoefficients returns the built-in coefficients
+func DefaultCoefficients() *Coefficients {
+	return &Coefficients{
+		// the jitter buffer needs to hold about twice the jitter to avoid late packets
+		JitterBufferFactor: 2,
+		JitterAllowance:    10,
+		Audio: AudioCoefficients{
+			PacketizationDelay: 20,
+			IeIntercept:        55,
+			IeSlope:            4.6,
+			IeMax:              30,
+			IeDtx:              8,
+			IeUnknownBitrate:   6,
+			Bpl:                10,
+			BplFec:             20,
+			// with 2 packets redundancy, should be able to absorb 2 out of every 3 packets lost without quality impact,
+			// set this value so that even significant loss rate (i. e. something like 10%) does not affect score a lot.
+			BplRed:                    90,
+			DelayFactor:               0.03,
+			DelayThreshold:            150,
+			DelayFactorAboveThreshold: 0.1,
+		},
+		Video: VideoCoefficients{
+			BPPPFSlope:      0.56,
+			BPPPFIntercept:  5.36,
+			FrameRateFactor: 1.9,
+			DelayFactor:     0.002,
+			CodecFactors: map[string]float64{
+				// assuming approximately 83% of vp8/h.264 bitrate for same quality
+				"vp9": 1.2,
+				// assuming approximately 70% of vp8/h.264 bitrate for same quality
+				"av1": 1.43,
+			},
+			Bpl:         5,
+			FecRecovery: 0.5,
+			// retransmissions are rendered late past this round trip time
+			NackMaxRoundTripTime: 400,
+			// stalls are perceived much more severely than a lower frame rate, even short and rare ones
+			FreezeDurationWeight: 6,
+			FreezeCountWeight:    0.02,
+		},
+		Audiovisual: AudiovisualCoefficients{
+			// ITU-T G.1070 / P.911 style regression of the multimedia quality on the audio and video quality,
+			// video quality dominates and a poor audio quality drags down a good video quality
+			Intercept:     1.12,
+			AudioFactor:   0.007,
+			VideoFactor:   0.24,
+			ProductFactor: 0.088,
+			// ITU-R BT.1359 thresholds, audio leading the video is noticed much earlier than audio lagging
+			SyncLeadDetectability:    45,
+			SyncLagDetectability:     125,
+			SyncLeadAcceptability:    90,
+			SyncLagAcceptability:     185,
+			SyncAcceptabilityPenalty: 0.5,
+			SyncFactor:               0.008,
+		},
+	}
+}
+
+// Clone returns a deep copy of the coefficients
+func (c *Coefficients) Clone() *Coefficients {
+	cloned := *c
+	cloned.Video.CodecFactors = make(map[string]float64, len(c.Video.CodecFactors))
+	for codec, factor := range c.Video.CodecFactors {
+		cloned.Video.CodecFactors[codec] = factor
+	}
+	cloned.Audio.Configurations = append([]AudioConfigurationCoefficients(nil), c.Audio.Configurations...)
+	return &cloned
+}
+
+// read-only, used when no coefficients are passed
+var defaultCoefficients = DefaultCoefficients()
+
+func (c *Coefficients) orDefault() *Coefficients {
+	if c == nil {
+		return defaultCoefficients
+	}
+	return c
+}
+
+// key - flags and bandwidth of the configuration, the empty bandwidth being narrowband
+func (c AudioConfigurationCoefficients) key() AudioConfigurationCoefficients {
+	key := AudioConfigurationCoefficients{Fec: c.Fec, Dtx: c.Dtx, Red: c.Red, Bandwidth: c.Bandwidth}
+	if key.Bandwidth == "" {
+		key.Bandwidth = AudioBandwidthNarrowband
+	}
+	return key
+}
+
+// forConfig - coefficients of the opus configuration of the normalized audio config,
+// c itself when there is no matching configuration
+func (c *AudioCoefficients) forConfig(audioConfig *AudioConfig) *AudioCoefficients {
+	key := AudioConfigurationCoefficients{
+		Fec:       *audioConfig.Fec,
+		Dtx:       *audioConfig.Dtx,
+		Red:       *audioConfig.Red,
+		Bandwidth: audioConfig.Bandwidth,
+	}.key()
+	for _, configuration := range c.Configurations {
+		if configuration.key() != key {
+			continue
+		}
+		coefficients := *c
+		coefficients.IeIntercept = configuration.IeIntercept
+		coefficients.IeDtx = configuration.IeDtx
+		coefficients.IeUnknownBitrate = configuration.IeUnknownBitrate
+		switch {
+		case key.Red:
+			coefficients.BplRed = configuration.Bpl
+		case key.Fec:
+			coefficients.BplFec = configuration.Bpl
+		default:
+			coefficients.Bpl = configuration.Bpl
+		}
+		return &coefficients
+	}
+	return c
+}
+
+// codecFactor - bitrate efficiency of the codec, 1 for vp8/h.264 and unknown codecs
+func (c *VideoCoefficients) codecFactor(codec string) float64 {
+	if factor, ok := c.CodecFactors[strings.ToLower(codec)]; ok {
+		return factor
+	}
+	return 1
+}
+
+// ParseCoefficientsJSON parses JSON coefficients, missing fields keep their default value
+func ParseCoefficientsJSON(data []byte) (*Coefficients, error) {
+	coefficients := DefaultCoefficients()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(coefficients); err != nil {
+		return nil, err
+	}
+	if err := coefficients.Validate(); err != nil {
+		return nil, err
+	}
+	return coefficients, nil
+}
+
+// ParseCoefficientsYAML parses YAML coefficients, missing fields keep their default value
+func ParseCoefficientsYAML(data []byte) (*Coefficients, error) {
+	coefficients := DefaultCoefficients()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(coefficients); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err := coefficients.Validate(); err != nil {
+		return nil, err
+	}
+	return coefficients, nil
+}
+
+// LoadCoefficients reads a coefficients file, YAML for .yaml / .yml extensions, JSON otherwise
+func LoadCoefficients(path string) (*Coefficients, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var coefficients *Coefficients
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		coefficients, err = ParseCoefficientsYAML(data)
+	default:
+		coefficients, err = ParseCoefficientsJSON(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load coefficients from %s: %w", path, err)
+	}
+	return coefficients, nil
+}
+
+// SaveCoefficients writes the coefficients to a file, YAML for .yaml / .yml extensions, JSON otherwise
+func SaveCoefficients(path string, coefficients *Coefficients) error {
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(coefficients)
+	default:
+		data, err = json.MarshalIndent(coefficients, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// coefficientCheck - a coefficient must be finite and non negative, or positive
+type coefficientCheck struct {
+	field    string
+	value    float64
+	positive bool
+}
+
+// Validate checks that the coefficients can be used for scoring, returns a *ValidationError otherwise
+func (c *Coefficients) Validate() error {
+	checks := []coefficientCheck{
+		{"JitterBufferFactor", c.JitterBufferFactor, false},
+		{"JitterAllowance", c.JitterAllowance, false},
+		{"Audio.PacketizationDelay", c.Audio.PacketizationDelay, false},
+		{"Audio.IeIntercept", c.Audio.IeIntercept, false},
+		{"Audio.IeSlope", c.Audio.IeSlope, false},
+		{"Audio.IeMax", c.Audio.IeMax, false},
+		{"Audio.IeDtx", c.Audio.IeDtx, false},
+		{"Audio.IeUnknownBitrate", c.Audio.IeUnknownBitrate, false},
+		{"Audio.Bpl", c.Audio.Bpl, true},
+		{"Audio.BplFec", c.Audio.BplFec, true},
+		{"Audio.BplRed", c.Audio.BplRed, true},
+		{"Audio.DelayFactor", c.Audio.DelayFactor, false},
+		{"Audio.DelayThreshold", c.Audio.DelayThreshold, false},
+		{"Audio.DelayFactorAboveThreshold", c.Audio.DelayFactorAboveThreshold, false},
+		{"Video.BPPPFSlope", c.Video.BPPPFSlope, true},
+		{"Video.FrameRateFactor", c.Video.FrameRateFactor, false},
+		{"Video.DelayFactor", c.Video.DelayFactor, false},
+		{"Video.Bpl", c.Video.Bpl, true},
+		{"Video.FecRecovery", c.Video.FecRecovery, false},
+		{"Video.NackMaxRoundTripTime", c.Video.NackMaxRoundTripTime, true},
+		{"Video.FreezeDurationWeight", c.Video.FreezeDurationWeight, false},
+		{"Video.FreezeCountWeight", c.Video.FreezeCountWeight, false},
+		{"Audiovisual.AudioFactor", c.Audiovisual.AudioFactor, false},
+		{"Audiovisual.VideoFactor", c.Audiovisual.VideoFactor, false},
+		{"Audiovisual.ProductFactor", c.Audiovisual.ProductFactor, false},
+		{"Audiovisual.SyncLeadDetectability", c.Audiovisual.SyncLeadDetectability, false},
+		{"Audiovisual.SyncLagDetectability", c.Audiovisual.SyncLagDetectability, false},
+		{"Audiovisual.SyncLeadAcceptability", c.Audiovisual.SyncLeadAcceptability, true},
+		{"Audiovisual.SyncLagAcceptability", c.Audiovisual.SyncLagAcceptability, true},
+		{"Audiovisual.SyncAcceptabilityPenalty", c.Audiovisual.SyncAcceptabilityPenalty, false},
+		{"Audiovisual.SyncFactor", c.Audiovisual.SyncFactor, false},
+	}
+	for codec, factor := range c.Video.CodecFactors {
+		checks = append(checks, coefficientCheck{fmt.Sprintf("Video.CodecFactors[%s]", codec), factor, true})
+	}
+	for i, configuration := range c.Audio.Configurations {
+		field := fmt.Sprintf("Audio.Configurations[%d]", i)
+		checks = append(checks,
+			coefficientCheck{field + ".IeIntercept", configuration.IeIntercept, false},
+			coefficientCheck{field + ".IeDtx", configuration.IeDtx, false},
+			coefficientCheck{field + ".IeUnknownBitrate", configuration.IeUnknownBitrate, false},
+			coefficientCheck{field + ".Bpl", configuration.Bpl, true},
+		)
+	}
+
+	for _, check := range checks {
+		if math.IsNaN(check.value) || math.IsInf(check.value, 0) {
+			return &ValidationError{Field: check.field, Reason: "must be a finite number"}
+		}
+		if check.positive && check.value <= 0 {
+			return &ValidationError{Field: check.field, Reason: "must be positive"}
+		}
+		if check.value < 0 {
+			return &ValidationError{Field: check.field, Reason: "must not be negative"}
+		}
+	}
+
+	// BPPPFIntercept and Audiovisual.Intercept may be negative but must be finite
+	if math.IsNaN(c.Video.BPPPFIntercept) || math.IsInf(c.Video.BPPPFIntercept, 0) {
+		return &ValidationError{Field: "Video.BPPPFIntercept", Reason: "must be a finite number"}
+	}
+	if math.IsNaN(c.Audiovisual.Intercept) || math.IsInf(c.Audiovisual.Intercept, 0) {
+		return &ValidationError{Field: "Audiovisual.Intercept", Reason: "must be a finite number"}
+	}
+	if c.Audio.IeMax > 100 {
+		return &ValidationError{Field: "Audio.IeMax", Reason: "must not exceed 100"}
+	}
+	if c.Audiovisual.SyncLeadAcceptability <= c.Audiovisual.SyncLeadDetectability {
+		return &ValidationError{Field: "Audiovisual.SyncLeadAcceptability", Reason: "must exceed SyncLeadDetectability"}
+	}
+	if c.Audiovisual.SyncLagAcceptability <= c.Audiovisual.SyncLagDetectability {
+		return &ValidationError{Field: "Audiovisual.SyncLagAcceptability", Reason: "must exceed SyncLagDetectability"}
+	}
+	seen := map[AudioConfigurationCoefficients]bool{}
+	for i, configuration := range c.Audio.Configurations {
+		field := fmt.Sprintf("Audio.Configurations[%d].Bandwidth", i)
+		switch configuration.Bandwidth {
+		case "", AudioBandwidthNarrowband, AudioBandwidthWideband, AudioBandwidthFullband:
+		default:
+			return &ValidationError{Field: field, Reason: fmt.Sprintf("unknown bandwidth %q", configuration.Bandwidth)}
+		}
+		key := configuration.key()
+		if seen[key] {
+			return &ValidationError{Field: fmt.Sprintf("Audio.Configurations[%d]", i), Reason: "duplicate flags"}
+		}
+		seen[key] = true
+	}
+	if c.Video.FecRecovery > 1 {
+		return &ValidationError{Field: "Video.FecRecovery", Reason: "must be a share between 0 and 1"}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package rtcmos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoefficients(t *testing.T) {
+	{
+		// default coefficients are valid and match the built-in scores
+		coefficients := DefaultCoefficients()
+		require.NoError(t, coefficients.Validate())
+
+		stats := []Stat{
+			{PacketLoss: 5, Jitter: 40, AudioConfig: &AudioConfig{Dtx: boolPtr(true)}},
+			{Bitrate: 1700000, PacketLoss: 2, VideoConfig: &VideoConfig{Codec: "vp9"}},
+		}
+		require.Equal(t, Score(stats), []Scores{AudioScoreWith(stats[0], coefficients), VideoScoreWith(stats[1], coefficients)})
+	}
+	{
+		// partial JSON keeps defaults for missing fields
+		coefficients, err := ParseCoefficientsJSON([]byte(`{"video": {"bpppf_intercept": 5.5, "codec_factors": {"h265": 1.3}}}`))
+		require.NoError(t, err)
+		require.Equal(t, 5.5, coefficients.Video.BPPPFIntercept)
+		require.Equal(t, 0.56, coefficients.Video.BPPPFSlope)
+		require.Equal(t, 1.3, coefficients.Video.codecFactor("H265"))
+		require.Equal(t, 1.2, coefficients.Video.codecFactor("vp9"))
+
+		stat := Stat{Bitrate: 1700000, VideoConfig: &VideoConfig{}}
+		require.Greater(t, VideoScoreWith(stat, coefficients).VideoScore, VideoScore(stat).VideoScore)
+	}
+	{
+		// YAML coefficients
+		coefficients, err := ParseCoefficientsYAML([]byte("audio:\n  bpl_fec: 40\n  delay_factor: 0.05\n"))
+		require.NoError(t, err)
+		require.Equal(t, 40.0, coefficients.Audio.BplFec)
+		require.Equal(t, 0.05, coefficients.Audio.DelayFactor)
+
+		stat := Stat{PacketLoss: 10, AudioConfig: &AudioConfig{}}
+		require.NotEqual(t, AudioScore(stat), AudioScoreWith(stat, coefficients))
+	}
+	{
+		// invalid coefficients are rejected
+		_, err := ParseCoefficientsJSON([]byte(`{"audio": {"bpl": 0}}`))
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, "Audio.Bpl", validationErr.Field)
+
+		_, err = ParseCoefficientsYAML([]byte("video:\n  fec_recovery: 2\n"))
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, "Video.FecRecovery", validationErr.Field)
+
+		_, err = ParseCoefficientsJSON([]byte(`{"video": {"unknown": 1}}`))
+		require.Error(t, err)
+
+		_, err = ParseCoefficientsYAML([]byte("audio:\n  configurations:\n    - {fec: true, bpl: 0}\n"))
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, "Audio.Configurations[0].Bpl", validationErr.Field)
+
+		duplicate := `{"audio": {"configurations": [{"bpl": 5}, {"bandwidth": "narrowband", "bpl": 6}]}}`
+		_, err = ParseCoefficientsJSON([]byte(duplicate))
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, "Audio.Configurations[1]", validationErr.Field)
+	}
+	{
+		// configurations replace the opus impairments of their flags and bandwidth only
+		coefficients := DefaultCoefficients()
+		coefficients.Audio.Configurations = []AudioConfigurationCoefficients{
+			{Fec: true, Bandwidth: AudioBandwidthWideband, IeIntercept: 45, IeDtx: 8, IeUnknownBitrate: 6, Bpl: 40},
+		}
+		stat := Stat{Bitrate: 32000, PacketLoss: 5, AudioConfig: &AudioConfig{Bandwidth: AudioBandwidthWideband}}
+		scorer := NewScorer(coefficients)
+		require.Greater(t, scorer.AudioScore(stat).AudioScore, AudioScore(stat).AudioScore)
+
+		for _, other := range []*AudioConfig{
+			{Bandwidth: AudioBandwidthWideband, Fec: boolPtr(false)},
+			{Bandwidth: AudioBandwidthFullband},
+			{Bandwidth: AudioBandwidthWideband, Codec: AudioCodecG722},
+		} {
+			stat := Stat{Bitrate: 32000, PacketLoss: 5, AudioConfig: other}
+			require.Equal(t, AudioScore(stat), scorer.AudioScore(stat))
+		}
+	}
+	{
+		// files are loaded according to their extension
+		dir := t.TempDir()
+		jsonPath := filepath.Join(dir, "coefficients.json")
+		yamlPath := filepath.Join(dir, "coefficients.yml")
+		require.NoError(t, os.WriteFile(jsonPath, []byte(`{"jitter_buffer_factor": 3}`), 0644))
+		require.NoError(t, os.WriteFile(yamlPath, []byte("jitter_buffer_factor: 4\n"), 0644))
+
+		coefficients, err := LoadCoefficients(jsonPath)
+		require.NoError(t, err)
+		require.Equal(t, 3.0, coefficients.JitterBufferFactor)
+
+		coefficients, err = LoadCoefficients(yamlPath)
+		require.NoError(t, err)
+		require.Equal(t, 4.0, coefficients.JitterBufferFactor)
+
+		_, err = LoadCoefficients(filepath.Join(dir, "missing.json"))
+		require.Error(t, err)
+
+		// saved coefficients load back identically
+		saved := DefaultCoefficients()
+		saved.Video.BPPPFSlope = 0.6
+		saved.Audio.Configurations = []AudioConfigurationCoefficients{
+			{Red: true, Bandwidth: AudioBandwidthFullband, IeIntercept: 50, IeDtx: 10, IeUnknownBitrate: 5, Bpl: 60},
+		}
+		for _, name := range []string{"saved.json", "saved.yaml"} {
+			path := filepath.Join(dir, name)
+			require.NoError(t, SaveCoefficients(path, saved))
+			coefficients, err = LoadCoefficients(path)
+			require.NoError(t, err)
+			require.Equal(t, saved, coefficients)
+		}
+	}
+	{
+		// scorer with custom coefficients
+		coefficients := DefaultCoefficients()
+		coefficients.Video.FrameRateFactor = 0
+		scorer := NewScorer(coefficients)
+		stat := Stat{Bitrate: 500000, VideoConfig: &VideoConfig{FrameRate: float32Ptr(15), ExpectedFrameRate: float32Ptr(30)}}
+		require.Greater(t, scorer.VideoScore(stat).VideoScore, VideoScore(stat).VideoScore)
+	}
+}
@@ -0,0 +1,186 @@
+package rtcmos
+
+import (
+	"math"
+)
+
+// EModelParams contains the transmission parameters of the ITU-T G.107 E-model
+// which are not derived from the Stat (delays, packet loss, Ie and Bpl are).
+// Unset fields are not filled from DefaultEModelParams, start from a copy of them to change a few values.
+type EModelParams struct {
+	// SLR: send loudness rating (dB)
+	SLR float64
+	// RLR: receive loudness rating (dB)
+	RLR float64
+	// STMR: sidetone masking rating (dB)
+	STMR float64
+	// LSTR: listener sidetone rating (dB)
+	LSTR float64
+	// Ds: D-value of telephone, send side
+	Ds float64
+	// Dr: D-value of telephone, receive side
+	Dr float64
+	// TELR: talker echo loudness rating (dB)
+	TELR float64
+	// WEPL: weighted echo path loss (dB)
+	WEPL float64
+	// Qdu: number of quantization distortion units
+	Qdu float64
+	// Nc: circuit noise referred to 0 dBr-point (dBm0p)
+	Nc float64
+	// Nfor: noise floor at the receive side (dBmp)
+	Nfor float64
+	// Ps: room noise at the send side (dB(A))
+	Ps float64
+	// Pr: room noise at the receive side (dB(A))
+	Pr float64
+	// A: advantage factor
+	A float64
+	// MT: minimum perceivable delay for Idd (ms)
+	MT float64
+	// ST: delay sensitivity for Idd
+	ST float64
+}
+
+// DefaultEModelParams returns the default values of ITU-T G.107 Table 3
+func DefaultEModelParams() *EModelParams {
+	return &EModelParams{
+		SLR:  8,
+		RLR:  2,
+		STMR: 15,
+		LSTR: 18,
+		Ds:   3,
+		Dr:   3,
+		TELR: 65,
+		WEPL: 110,
+		Qdu:  1,
+		Nc:   -70,
+		Nfor: -64,
+		Ps:   35,
+		Pr:   35,
+		A:    0,
+		MT:   100,
+		ST:   1,
+	}
+}
+
+// eModelConditions contains the E-model inputs derived from the Stat
+type eModelConditions struct {
+	// T: mean one-way delay of the echo path (ms)
+	T float64
+	// Ta: absolute delay in echo-free connections (ms)
+	Ta float64
+	// Tr: round-trip delay in a 4-wire loop (ms)
+	Tr float64
+	// Ie: equipment impairment factor
+	Ie float64
+	// Bpl: packet-loss robustness factor
+	Bpl float64
+	// Ppl: packet-loss probability (percent)
+	Ppl float64
+	// BurstR: burst ratio, 1 for random loss
+	BurstR float64
+}
+
+// EModelImpairments contains the terms of R = Ro - Is - Id - Ie-eff + A, on the scale of the audio bandwidth
+type EModelImpairments struct {
+	// Ro: basic signal-to-noise ratio
+	Ro float64
+	// Is: simultaneous impairment factor, loudness and quantization
+	Is float64
+	// Id: delay impairment factor, echo and absolute delay
+	Id float64
+	// IeEff: effective equipment impairment factor, codec and packet loss
+	IeEff float64
+	// A: advantage factor
+	A float64
+}
+
+// R returns the transmission rating factor
+func (i EModelImpairments) R() float64 {
+	return i.Ro - i.Is - i.Id - i.IeEff + i.A
+}
+
+// impairments - computes the E-model terms following ITU-T G.107 clause 7
+//
+// For wideband and fullband, ITU-T G.107.1 and G.107.2 replace Ro by 129 and 148 with Is = 0,
+// while the delay and loss impairments are stretched to the wider scale.
+func (p *EModelParams) impairments(bandwidth AudioBandwidth, c eModelConditions) EModelImpairments {
+	OLR := p.SLR + p.RLR
+
+	// basic signal-to-noise ratio
+	Nos := p.Ps - p.SLR - p.Ds - 100 + 0.004*math.Pow(p.Ps-OLR-p.Ds-14, 2)
+	Pre := p.Pr + 10*math.Log10(1+math.Pow(10, (10-p.LSTR)/10))
+	Nor := p.RLR - 121 + Pre + 0.008*math.Pow(Pre-35, 2)
+	Nfo := p.Nfor + p.RLR
+	No := 10 * math.Log10(math.Pow(10, p.Nc/10)+math.Pow(10, Nos/10)+math.Pow(10, Nor/10)+math.Pow(10, Nfo/10))
+	Ro := 15 - 1.5*(p.SLR+No)
+
+	// simultaneous impairment factor
+	Xolr := OLR + 0.2*(64+No-p.RLR)
+	Iolr := 20 * (math.Pow(1+math.Pow(Xolr/8, 8), 1.0/8) - Xolr/8)
+	STMRo := -10 * math.Log10(math.Pow(10, -p.STMR/10)+math.Exp(-c.T/4)*math.Pow(10, -p.TELR/10))
+	Ist := 12*math.Pow(1+math.Pow((STMRo-13)/6, 8), 1.0/8) -
+		28*math.Pow(1+math.Pow((STMRo+1)/19.4, 35), 1.0/35) -
+		13*math.Pow(1+math.Pow((STMRo-3)/33, 13), 1.0/13) + 29
+	Q := 37 - 15*math.Log10(p.Qdu)
+	G := 1.07 + 0.258*Q + 0.0602*Q*Q
+	Z := 46.0/30 - G/40
+	Y := (Ro-100)/15 + 46/8.4 - G/9
+	Iq := 15 * math.Log10(1+math.Pow(10, Y)+math.Pow(10, Z))
+	Is := Iolr + Ist + Iq
+
+	// delay impairment factor, talker echo
+	Roe := -1.5 * (No - p.RLR)
+	TERV := p.TELR - 40*math.Log10((1+c.T/10)/(1+c.T/150)) + 6*math.Exp(-0.3*c.T*c.T)
+	if p.STMR < 9 {
+		TERV += Ist / 2
+	}
+	Re := 80 + 2.5*(TERV-14)
+	var Idte float64
+	if c.T >= 1 {
+		Idte = ((Roe-Re)/2 + math.Sqrt(math.Pow(Roe-Re, 2)/4+100) - 1) * (1 - math.Exp(-c.T))
+	}
+	if p.STMR > 20 {
+		Idte = math.Sqrt(Idte*Idte + Ist*Ist)
+	}
+
+	// delay impairment factor, listener echo
+	Rle := 10.5 * (p.WEPL + 7) * math.Pow(c.Tr+1, -0.25)
+	Idle := (Ro-Rle)/2 + math.Sqrt(math.Pow(Ro-Rle, 2)/4+169)
+
+	// delay impairment factor, absolute delay
+	var Idd float64
+	if c.Ta > p.MT {
+		X := math.Log10(c.Ta/p.MT) / math.Log10(2)
+		e := 6 * p.ST
+		Idd = 25 * (math.Pow(1+math.Pow(X, e), 1/e) - 3*math.Pow(1+math.Pow(X/3, e), 1/e) + 2)
+	}
+
+	Id := Idte + Idle + Idd
+	if bandwidth.Scale() > 1 {
+		Ro = 100 * bandwidth.Scale()
+		Is = 0
+		Id *= bandwidth.Scale()
+	}
+
+	// effective equipment impairment factor
+	var IeEff float64
+	if c.Ppl > 0 {
+		burstR := c.BurstR
+		if burstR <= 0 {
+			burstR = 1
+		}
+		IeEff = c.Ie + (95*bandwidth.Scale()-c.Ie)*c.Ppl/(c.Ppl/burstR+c.Bpl)
+	} else {
+		IeEff = c.Ie
+	}
+
+	return EModelImpairments{
+		Ro:    Ro,
+		Is:    Is,
+		Id:    Id,
+		IeEff: IeEff,
+		A:     p.A,
+	}
+}
@@ -0,0 +1,83 @@
+package rtcmos
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEModel(t *testing.T) {
+	{
+		// default parameters yield the reference rating of G.107
+		impairments := DefaultEModelParams().impairments(AudioBandwidthNarrowband, eModelConditions{})
+		t.Log("Ro", impairments.Ro, "Is", impairments.Is, "Id", impairments.Id, "R", impairments.R())
+		require.InDelta(t, 94.77, impairments.Ro, 0.05)
+		require.InDelta(t, 1.41, impairments.Is, 0.05)
+		require.InDelta(t, 93.2, impairments.R(), 0.1)
+	}
+	{
+		// absolute delay below mT does not impair, above it does
+		params := DefaultEModelParams()
+		short := params.impairments(AudioBandwidthNarrowband, eModelConditions{T: 90, Ta: 90, Tr: 180})
+		long := params.impairments(AudioBandwidthNarrowband, eModelConditions{T: 400, Ta: 400, Tr: 800})
+		require.Greater(t, short.R(), long.R())
+		require.Less(t, long.R(), 80.0)
+	}
+	{
+		// packet loss impairs with respect to Bpl
+		params := DefaultEModelParams()
+		robust := params.impairments(AudioBandwidthNarrowband, eModelConditions{Ppl: 5, Bpl: 25.1})
+		fragile := params.impairments(AudioBandwidthNarrowband, eModelConditions{Ppl: 5, Bpl: 4.3})
+		require.Greater(t, robust.R(), fragile.R())
+		require.InDelta(t, 95*5/(5+4.3), fragile.IeEff, 0.01)
+	}
+	{
+		// audio score with the G.107 model
+		stat := Stat{
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			AudioConfig:   &AudioConfig{Model: AudioModelG107},
+		}
+		scores := Score([]Stat{stat})
+		require.Len(t, scores, 1)
+		require.GreaterOrEqual(t, scores[0].AudioScore, 4.0)
+		require.LessOrEqual(t, scores[0].AudioScore, 4.5)
+	}
+	{
+		// G.107 audio score depends on packet loss and delay
+		stat1 := Stat{
+			PacketLoss:  5,
+			AudioConfig: &AudioConfig{Model: AudioModelG107},
+		}
+		stat2 := Stat{
+			PacketLoss:  20,
+			AudioConfig: &AudioConfig{Model: AudioModelG107},
+		}
+		stat3 := Stat{
+			PacketLoss:    5,
+			RoundTripTime: int32Ptr(600),
+			AudioConfig:   &AudioConfig{Model: AudioModelG107},
+		}
+		scores := Score([]Stat{stat1, stat2, stat3})
+		require.Len(t, scores, 3)
+		require.Greater(t, scores[0].AudioScore, scores[1].AudioScore)
+		require.Greater(t, scores[0].AudioScore, scores[2].AudioScore)
+	}
+	{
+		// partly filled parameters are rejected instead of producing NaN scores
+		stat := Stat{AudioConfig: &AudioConfig{Model: AudioModelG107, EModel: &EModelParams{A: 5}}}
+		results := Evaluate([]Stat{stat})
+		var validationErr *ValidationError
+		require.ErrorAs(t, results[0].Err, &validationErr)
+		require.Equal(t, "AudioConfig.EModel.Qdu", validationErr.Field)
+
+		params := DefaultEModelParams()
+		params.A = 5
+		stat.AudioConfig.EModel = params
+		results = Evaluate([]Stat{stat})
+		require.NoError(t, results[0].Err)
+		require.False(t, math.IsNaN(results[0].Scores.AudioScore))
+		require.Greater(t, results[0].Scores.AudioScore, Score([]Stat{{AudioConfig: &AudioConfig{Model: AudioModelG107}}})[0].AudioScore)
+	}
+}
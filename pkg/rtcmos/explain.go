@@ -0,0 +1,188 @@
+package rtcmos
+
+import (
+	"errors"
+	"math"
+)
+
+// Impairment names a factor lowering a score
+type Impairment string
+
+const (
+	// ImpairmentNone: nothing lowers the score
+	ImpairmentNone Impairment = ""
+	// ImpairmentCodec: audio codec and bitrate (Ie)
+	ImpairmentCodec Impairment = "codec"
+	// ImpairmentBitrate: video bits per pixel per frame below what gives the best score
+	ImpairmentBitrate Impairment = "bitrate"
+	// ImpairmentPacketLoss: packet loss left after recovery (audio Ie-eff - Ie, video residual loss)
+	ImpairmentPacketLoss Impairment = "packet_loss"
+	// ImpairmentDelay: mouth to ear or glass to glass delay
+	ImpairmentDelay Impairment = "delay"
+	// ImpairmentFrameRate: video frame rate below the expected frame rate
+	ImpairmentFrameRate Impairment = "frame_rate"
+	// ImpairmentFreezes: video freezes
+	ImpairmentFreezes Impairment = "freezes"
+)
+
+// ErrExplainUnsupported is returned when the scorer does not implement Explainer
+var ErrExplainUnsupported = errors.New("scorer does not explain its scores")
+
+// Contribution is the share of a score lost to a factor
+type Contribution struct {
+	Impairment Impairment
+	// Value: model term of the factor. Audio terms are on the R scale of the bandwidth (Ie, Ie-eff - Ie, Id),
+	// video terms are the bPPPF, the frame rate and delay penalties, the residual loss percentage
+	// and the share of quality lost to freezes
+	Value float64
+	// MOS: score lost to the factor, contributions add up to the best score of the model minus the score
+	MOS float64
+}
+
+// Explanation contains the scores of a Stat and the factors which lowered them
+type Explanation struct {
+	Scores Scores
+	// Contributions: score lost to each factor of the model, in the order of the model
+	Contributions []Contribution
+	// Dominant: factor losing the most score, ImpairmentNone when no score is lost
+	Dominant Impairment
+	// Err: *ValidationError when the stat is invalid, ErrExplainUnsupported when the scorer cannot explain
+	Err error
+}
+
+// Explainer is implemented by scorers able to break down their scores
+type Explainer interface {
+	// Explain - scores a valid stat and attributes the score lost to each factor
+	Explain(stat Stat) Explanation
+}
+
+// Explain validates, scores and breaks down the passed stats with the default scorer
+//
+// returns an explanation for each input
+func Explain(stats []Stat) []Explanation {
+	_, scorer := DefaultScorer()
+	return ExplainWith(scorer, stats)
+}
+
+// ExplainWith validates, scores and breaks down the passed stats with the given scorer
+//
+// returns an explanation for each input
+func ExplainWith(scorer Scorer, stats []Stat) []Explanation {
+	explainer, ok := scorer.(Explainer)
+	explanations := make([]Explanation, 0, len(stats))
+	for _, stat := range stats {
+		if err := stat.Validate(); err != nil {
+			explanations = append(explanations, Explanation{Err: err})
+		} else if !ok {
+			explanations = append(explanations, Explanation{Err: ErrExplainUnsupported})
+		} else {
+			explanations = append(explanations, explainer.Explain(stat))
+		}
+	}
+	return explanations
+}
+
+func (s coefficientsScorer) Explain(stat Stat) Explanation {
+	if stat.AudioConfig != nil {
+		return explainAudio(stat, s.coefficients)
+	}
+	return explainVideo(stat, s.coefficients)
+}
+
+// explainAudio - the R terms are additive, the score lost is split in proportion to them
+func explainAudio(input Stat, coefficients *Coefficients) Explanation {
+	coefficients = coefficients.orDefault()
+	scores := AudioScoreWith(input, coefficients)
+	stat := normalizeAudioStat(input, coefficients)
+	bandwidth := stat.AudioConfig.Bandwidth
+
+	Ie, _ := audioEquipmentImpairment(stat, &coefficients.Audio)
+	impairments := scores.Impairments
+	// Is and A do not depend on the stat and are part of the best score
+	best := impairments
+	best.Id, best.IeEff = 0, 0
+	lost := mosFromR(clamp(best.R(), 0, 100*bandwidth.Scale()), bandwidth) - scores.AudioScore
+
+	contributions := []Contribution{
+		{Impairment: ImpairmentCodec, Value: Ie},
+		{Impairment: ImpairmentPacketLoss, Value: impairments.IeEff - Ie},
+		{Impairment: ImpairmentDelay, Value: impairments.Id},
+	}
+	share(contributions, lost, func(c Contribution) float64 {
+		return c.Value
+	})
+
+	return Explanation{Scores: scores, Contributions: contributions, Dominant: dominant(contributions)}
+}
+
+// explainVideo - the penalties on the regression are split in proportion to their size,
+// the quality lost to residual loss and freezes in proportion to their log factors
+func explainVideo(input Stat, coefficients *Coefficients) Explanation {
+	scores := VideoScoreWith(input, coefficients)
+	features, ok := ExtractVideoFeatures(input, coefficients)
+	if !ok {
+		// no frames, the whole score is lost to the frame rate
+		contributions := []Contribution{{Impairment: ImpairmentFrameRate, MOS: 5 - scores.VideoScore}}
+		return Explanation{Scores: scores, Contributions: contributions, Dominant: ImpairmentFrameRate}
+	}
+	c := &coefficients.orDefault().Video
+
+	base := clamp(c.BPPPFSlope*math.Log(features.BPPPF)+c.BPPPFIntercept, 1, 5)
+	frameRatePenalty := c.FrameRateFactor * math.Log(features.FrameRateRatio)
+	delayPenalty := features.Delay * c.DelayFactor
+	regression := clamp(base-frameRatePenalty-delayPenalty, 1, 5)
+
+	contributions := []Contribution{
+		{Impairment: ImpairmentBitrate, Value: features.BPPPF, MOS: 5 - base},
+		{Impairment: ImpairmentFrameRate, Value: frameRatePenalty},
+		{Impairment: ImpairmentDelay, Value: delayPenalty},
+		{Impairment: ImpairmentPacketLoss, Value: features.ResidualLoss},
+		{Impairment: ImpairmentFreezes, Value: 1 - features.FreezeFactor},
+	}
+	if base-regression < 0 {
+		// a frame rate above the expected one is a bonus, it makes up for part of the bitrate
+		contributions[0].MOS += base - regression
+	} else {
+		share(contributions[1:3], base-regression, func(c Contribution) float64 {
+			return math.Max(0, c.Value)
+		})
+	}
+	// QualityKept = (1 - loss factor) * FreezeFactor
+	lossKept := 1.0
+	if features.FreezeFactor > 0 {
+		lossKept = features.QualityKept / features.FreezeFactor
+	}
+	share(contributions[3:], (regression-1)*(1-features.QualityKept), func(c Contribution) float64 {
+		if c.Impairment == ImpairmentFreezes {
+			return -math.Log(math.Max(features.FreezeFactor, 1e-9))
+		}
+		return -math.Log(math.Max(lossKept, 1e-9))
+	})
+
+	return Explanation{Scores: scores, Contributions: contributions, Dominant: dominant(contributions)}
+}
+
+// share - sets the MOS of the contributions to their share of the score lost, in proportion to their weight
+func share(contributions []Contribution, lost float64, weight func(Contribution) float64) {
+	var total float64
+	for _, c := range contributions {
+		total += weight(c)
+	}
+	if total <= 0 || lost <= 0 {
+		return
+	}
+	for i := range contributions {
+		contributions[i].MOS = lost * weight(contributions[i]) / total
+	}
+}
+
+// dominant - impairment losing the most score
+func dominant(contributions []Contribution) Impairment {
+	impairment, lost := ImpairmentNone, 0.0
+	for _, c := range contributions {
+		if c.MOS > lost {
+			impairment, lost = c.Impairment, c.MOS
+		}
+	}
+	return impairment
+}
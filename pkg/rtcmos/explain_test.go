@@ -0,0 +1,126 @@
+package rtcmos
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// lostMOS - sum of the score lost to each factor
+func lostMOS(contributions []Contribution) float64 {
+	var lost float64
+	for _, c := range contributions {
+		lost += c.MOS
+	}
+	return lost
+}
+
+func TestExplain(t *testing.T) {
+	{
+		// audio: contributions add up to the score lost compared to perfect conditions
+		stat := Stat{
+			Bitrate:       8000,
+			PacketLoss:    1,
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			AudioConfig:   &AudioConfig{},
+		}
+		explanation := Explain([]Stat{stat})[0]
+		require.NoError(t, explanation.Err)
+		require.Equal(t, AudioScore(stat), explanation.Scores)
+		require.Equal(t, ImpairmentCodec, explanation.Dominant)
+		require.Len(t, explanation.Contributions, 3)
+		require.InDelta(t, 55-4.6*math.Log(8000), explanation.Contributions[0].Value, 1e-9)
+		require.InDelta(t, 4.5-explanation.Scores.AudioScore, lostMOS(explanation.Contributions), 1e-9)
+
+		// bad network
+		stat.Bitrate = 64000
+		stat.PacketLoss = 10
+		require.Equal(t, ImpairmentPacketLoss, Explain([]Stat{stat})[0].Dominant)
+		stat.PacketLoss = 0
+		stat.RoundTripTime = int32Ptr(600)
+		require.Equal(t, ImpairmentDelay, Explain([]Stat{stat})[0].Dominant)
+	}
+	{
+		// audio with the G.107 model: Is is part of the best score
+		explanation := Explain([]Stat{{Bitrate: 64000, AudioConfig: &AudioConfig{Model: AudioModelG107}}})[0]
+		require.NoError(t, explanation.Err)
+		best := mosFromR(explanation.Scores.Impairments.Ro-explanation.Scores.Impairments.Is, AudioBandwidthNarrowband)
+		require.InDelta(t, best-explanation.Scores.AudioScore, lostMOS(explanation.Contributions), 1e-9)
+	}
+	{
+		// video: contributions add up to the score lost compared to 5
+		stat := Stat{
+			Bitrate:       2500000,
+			PacketLoss:    5,
+			RoundTripTime: int32Ptr(500),
+			BufferDelay:   int32Ptr(100),
+			VideoConfig: &VideoConfig{
+				Width:             int32Ptr(1280),
+				Height:            int32Ptr(720),
+				FrameRate:         float32Ptr(15),
+				ExpectedFrameRate: float32Ptr(30),
+				FreezeCount:       int32Ptr(1),
+			},
+		}
+		explanation := Explain([]Stat{stat})[0]
+		require.NoError(t, explanation.Err)
+		require.Equal(t, VideoScore(stat), explanation.Scores)
+		require.Len(t, explanation.Contributions, 5)
+		for _, c := range explanation.Contributions {
+			t.Log(c.Impairment, c.Value, c.MOS)
+			require.Greater(t, c.MOS, 0.0)
+		}
+		require.InDelta(t, 5-explanation.Scores.VideoScore, lostMOS(explanation.Contributions), 1e-9)
+		require.Equal(t, ImpairmentFrameRate, explanation.Dominant)
+
+		// nothing lost at a high bitrate without impairments
+		stat = Stat{Bitrate: 20000000, RoundTripTime: int32Ptr(0), BufferDelay: int32Ptr(0), VideoConfig: &VideoConfig{}}
+		explanation = Explain([]Stat{stat})[0]
+		require.Equal(t, ImpairmentNone, explanation.Dominant)
+		require.Equal(t, 0.0, lostMOS(explanation.Contributions))
+
+		// no frames: the frame rate is the cause of the lowest score
+		frozen := Explain([]Stat{{VideoConfig: &VideoConfig{FrameRate: float32Ptr(0)}}})[0]
+		require.Equal(t, 1.0, frozen.Scores.VideoScore)
+		require.Equal(t, ImpairmentFrameRate, frozen.Dominant)
+		require.InDelta(t, 4, lostMOS(frozen.Contributions), 1e-9)
+	}
+	{
+		// video: a frame rate above the expected one offsets the bitrate, contributions still add up
+		stat := Stat{
+			Bitrate:       1700000,
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			VideoConfig: &VideoConfig{
+				Width:             int32Ptr(1280),
+				Height:            int32Ptr(720),
+				FrameRate:         float32Ptr(60),
+				ExpectedFrameRate: float32Ptr(30),
+			},
+		}
+		explanation := Explain([]Stat{stat})[0]
+		require.NoError(t, explanation.Err)
+		require.InDelta(t, 5-explanation.Scores.VideoScore, lostMOS(explanation.Contributions), 1e-9)
+		require.InDelta(t, 5-explanation.Scores.VideoScore, explanation.Contributions[0].MOS, 1e-9)
+		for _, c := range explanation.Contributions {
+			require.GreaterOrEqual(t, c.MOS, 0.0)
+		}
+
+		// with a delay penalty smaller than the bonus
+		stat.BufferDelay = int32Ptr(200)
+		explanation = Explain([]Stat{stat})[0]
+		require.InDelta(t, 5-explanation.Scores.VideoScore, lostMOS(explanation.Contributions), 1e-9)
+		require.Equal(t, 0.0, explanation.Contributions[2].MOS)
+	}
+	{
+		// invalid stats and scorers without explanations
+		explanations := Explain([]Stat{{}})
+		require.Len(t, explanations, 1)
+		require.Error(t, explanations[0].Err)
+
+		explanations = ExplainWith(constantScorer{score: 3}, []Stat{{AudioConfig: &AudioConfig{}}})
+		require.ErrorIs(t, explanations[0].Err, ErrExplainUnsupported)
+	}
+}
@@ -0,0 +1,136 @@
+package rtcmos
+
+// QualityLevel is a discrete quality bucket of a score, ordered from QualityLost to QualityExcellent
+type QualityLevel int
+
+const (
+	// QualityLost: score below the poor threshold, or no media
+	QualityLost QualityLevel = iota
+	QualityPoor
+	QualityGood
+	QualityExcellent
+)
+
+const (
+	// DefaultQualityHysteresis: margin a score must cross past a threshold to change the level
+	DefaultQualityHysteresis = 0.1
+	// DefaultQualityDwell: minimum time a level is kept before changing (ms)
+	DefaultQualityDwell = 2000
+)
+
+func (l QualityLevel) String() string {
+	switch l {
+	case QualityExcellent:
+		return "excellent"
+	case QualityGood:
+		return "good"
+	case QualityPoor:
+		return "poor"
+	default:
+		return "lost"
+	}
+}
+
+// QualityThresholds contains the lowest score of each level
+type QualityThresholds struct {
+	Excellent float64
+	Good      float64
+	Poor      float64
+}
+
+// DefaultQualityThresholds returns the built-in thresholds, 3.5 being good quality for video (see VideoScore)
+func DefaultQualityThresholds() QualityThresholds {
+	return QualityThresholds{
+		Excellent: 4.1,
+		Good:      3.5,
+		Poor:      2.5,
+	}
+}
+
+// Validate checks that the thresholds are ordered, returns a *ValidationError otherwise
+func (t QualityThresholds) Validate() error {
+	if t.Poor <= 0 {
+		return &ValidationError{Field: "Poor", Reason: "must be positive"}
+	}
+	if t.Good <= t.Poor {
+		return &ValidationError{Field: "Good", Reason: "must be higher than Poor"}
+	}
+	if t.Excellent <= t.Good {
+		return &ValidationError{Field: "Excellent", Reason: "must be higher than Good"}
+	}
+	return nil
+}
+
+// Classify returns the level of the score without hysteresis, a score of 0 (no media) is QualityLost
+func (t QualityThresholds) Classify(score float64) QualityLevel {
+	switch {
+	case score >= t.Excellent:
+		return QualityExcellent
+	case score >= t.Good:
+		return QualityGood
+	case score >= t.Poor:
+		return QualityPoor
+	default:
+		return QualityLost
+	}
+}
+
+// QualityClassifier turns a stream of scores into a level which does not flap on every sample.
+//
+// The level goes up when the score exceeds the threshold of a higher level by UpHysteresis and goes down when the
+// score falls below the threshold of the current level by DownHysteresis. A level is kept at least MinDwell, except
+// when the media is lost (score of 0) which is reported immediately.
+type QualityClassifier struct {
+	Thresholds QualityThresholds
+	// UpHysteresis, DownHysteresis: margin past a threshold to go up or down a level
+	UpHysteresis   float64
+	DownHysteresis float64
+	// MinDwell: minimum time a level is kept before changing (ms)
+	MinDwell float64
+
+	level   QualityLevel
+	since   float64
+	started bool
+}
+
+// NewQualityClassifier returns a QualityClassifier with the thresholds, the default hysteresis and dwell time,
+// or a *ValidationError for thresholds which are not ordered
+func NewQualityClassifier(thresholds QualityThresholds) (*QualityClassifier, error) {
+	if err := thresholds.Validate(); err != nil {
+		return nil, err
+	}
+	return &QualityClassifier{
+		Thresholds:     thresholds,
+		UpHysteresis:   DefaultQualityHysteresis,
+		DownHysteresis: DefaultQualityHysteresis,
+		MinDwell:       DefaultQualityDwell,
+	}, nil
+}
+
+// Update ingests the score at the timestamp (ms) and returns the level, the first score sets the level directly
+func (c *QualityClassifier) Update(timestamp float64, score float64) QualityLevel {
+	if !c.started {
+		c.level, c.since, c.started = c.Thresholds.Classify(score), timestamp, true
+		return c.level
+	}
+
+	target := c.level
+	if up := c.Thresholds.Classify(score - c.UpHysteresis); up > c.level {
+		target = up
+	} else if down := c.Thresholds.Classify(score + c.DownHysteresis); down < c.level {
+		target = down
+	}
+	if score <= 0 {
+		target = QualityLost
+	}
+
+	if target != c.level && (score <= 0 || timestamp-c.since >= c.MinDwell) {
+		c.level, c.since = target, timestamp
+	}
+	return c.level
+}
+
+// Level returns the current level, QualityLost before the first score
+func (c *QualityClassifier) Level() QualityLevel {
+	return c.level
+}
@@ -0,0 +1,82 @@
+package rtcmos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQualityThresholds(t *testing.T) {
+	thresholds := DefaultQualityThresholds()
+	require.NoError(t, thresholds.Validate())
+	require.Equal(t, QualityExcellent, thresholds.Classify(4.5))
+	require.Equal(t, QualityGood, thresholds.Classify(3.5))
+	require.Equal(t, QualityPoor, thresholds.Classify(3))
+	require.Equal(t, QualityLost, thresholds.Classify(1))
+	require.Equal(t, QualityLost, thresholds.Classify(0))
+	require.Equal(t, "excellent", QualityExcellent.String())
+	require.Equal(t, "lost", QualityLost.String())
+
+	thresholds.Good = 4.5
+	var validationError *ValidationError
+	require.True(t, errors.As(thresholds.Validate(), &validationError))
+	require.Equal(t, "Excellent", validationError.Field)
+
+	thresholds = DefaultQualityThresholds()
+	thresholds.Good = thresholds.Poor
+	require.True(t, errors.As(thresholds.Validate(), &validationError))
+	require.Equal(t, "Good", validationError.Field)
+}
+
+func TestQualityClassifier(t *testing.T) {
+	{
+		// hysteresis: small oscillations around a threshold do not change the level
+		classifier, err := NewQualityClassifier(DefaultQualityThresholds())
+		require.NoError(t, err)
+		classifier.MinDwell = 0
+		require.Equal(t, QualityLost, classifier.Level())
+		require.Equal(t, QualityGood, classifier.Update(0, 3.8))
+		require.Equal(t, QualityGood, classifier.Update(1000, 3.45))
+		require.Equal(t, QualityPoor, classifier.Update(2000, 3.35))
+		require.Equal(t, QualityPoor, classifier.Update(3000, 3.55))
+		require.Equal(t, QualityGood, classifier.Update(4000, 3.65))
+		// jumps several levels at once
+		require.Equal(t, QualityExcellent, classifier.Update(5000, 4.5))
+		require.Equal(t, QualityLost, classifier.Update(6000, 1.5))
+	}
+
+	{
+		// separate up and down hysteresis
+		classifier, err := NewQualityClassifier(DefaultQualityThresholds())
+		require.NoError(t, err)
+		classifier.UpHysteresis, classifier.DownHysteresis, classifier.MinDwell = 0.3, 0, 0
+		classifier.Update(0, 3.6)
+		require.Equal(t, QualityGood, classifier.Update(1000, 4.3))
+		require.Equal(t, QualityExcellent, classifier.Update(2000, 4.4))
+		require.Equal(t, QualityGood, classifier.Update(3000, 4.09))
+	}
+
+	{
+		// dwell time: the level is kept at least MinDwell, except when the media is lost
+		classifier, err := NewQualityClassifier(DefaultQualityThresholds())
+		require.NoError(t, err)
+		classifier.Update(0, 4.5)
+		require.Equal(t, QualityExcellent, classifier.Update(1000, 3))
+		require.Equal(t, QualityPoor, classifier.Update(2000, 3))
+		require.Equal(t, QualityPoor, classifier.Update(3000, 4.5))
+		require.Equal(t, QualityLost, classifier.Update(3500, 0))
+		require.Equal(t, QualityLost, classifier.Update(4000, 4.5))
+		require.Equal(t, QualityExcellent, classifier.Update(5500, 4.5))
+	}
+
+	{
+		// thresholds which are not ordered are rejected
+		thresholds := DefaultQualityThresholds()
+		thresholds.Good = 2
+		_, err := NewQualityClassifier(thresholds)
+		var validationError *ValidationError
+		require.True(t, errors.As(err, &validationError))
+		require.Equal(t, "Good", validationError.Field)
+	}
+}
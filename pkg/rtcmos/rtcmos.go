@@ -1,13 +1,13 @@
 package rtcmos
 
 import (
-	"log"
 	"math"
 )
 
 const (
 	DefaultRoundTripTime = 50
 	DefaultBufferDelay   = 50
+	DefaultInterval      = 1000
 )
 
 // Stat defines the input parameter to calculate Score
@@ -16,36 +16,108 @@ type Stat struct {
 	Bitrate       float32
 	RoundTripTime *int32
 	BufferDelay   *int32
-	AudioConfig   *AudioConfig
-	VideoConfig   *VideoConfig
+	// Interval: duration of the measurement window (ms), defaults to DefaultInterval
+	Interval *int32
+	// Jitter: network interarrival jitter (ms)
+	Jitter float32
+	// JitterBufferDiscard: percentage of received packets discarded by the jitter buffer as late or early
+	JitterBufferDiscard float32
+	// SyncOffset: measured offset between the audio and the video of the participant (ms), positive when the audio
+	// leads the video, optional. Only used by AudiovisualScore, the lip-sync penalty is not applied when not set.
+	SyncOffset *int32
+	// Burst: distribution of the packet loss over time, optional
+	Burst       *BurstStat
+	AudioConfig *AudioConfig
+	VideoConfig *VideoConfig
 }
 
 // Scores contains to MOS audio and video scores
 type Scores struct {
-	// AudioScore: score based on modified E-model
+	// AudioScore: score based on modified E-model, conversational quality (MOS-CQ) including the delay impairment
 	AudioScore float64
+	// AudioListeningScore: listening quality (MOS-LQ) of the audio, AudioScore without the delay impairment
+	AudioListeningScore float64
+	// RFactor: transmission rating factor R behind AudioScore, on the scale of the audio bandwidth
+	RFactor float64
+	// Impairments: terms of RFactor, Is and A are 0 with the simplified model
+	Impairments EModelImpairments
 	// VideoScore: score based on logarithmic regression
 	VideoScore float64
+	// AudiovisualScore: overall score of an audio and video pair, only set by AudiovisualScore
+	AudiovisualScore float64
+	// SyncOffset: audio / video offset used by AudiovisualScore (ms), positive when the audio leads the video,
+	// 0 when not measured
+	SyncOffset float64
 }
 
-// Score compute audio and video scores for the passed stats
+// Result contains the scores of a Stat, or the reason why it could not be scored
+type Result struct {
+	Scores Scores
+	// Err: *ValidationError when the stat is invalid
+	Err error
+}
+
+// Evaluate validates and computes audio and video scores for the passed stats with the default scorer
 //
-// returns audio/video scores for each input
-func Score(stats []Stat) []Scores {
-	var scores []Scores
+// returns a result for each input
+func Evaluate(stats []Stat) []Result {
+	_, scorer := DefaultScorer()
+	return EvaluateWith(scorer, stats)
+}
+
+// EvaluateWith validates and computes audio and video scores for the passed stats with the given scorer
+//
+// returns a result for each input
+func EvaluateWith(scorer Scorer, stats []Stat) []Result {
+	results := make([]Result, 0, len(stats))
 	for _, stat := range stats {
-		if stat.AudioConfig != nil {
-			scores = append(scores, AudioScore(stat))
-		} else if stat.VideoConfig != nil {
-			scores = append(scores, VideoScore(stat))
+		if err := stat.Validate(); err != nil {
+			results = append(results, Result{Err: err})
+		} else if stat.AudioConfig != nil {
+			results = append(results, Result{Scores: scorer.AudioScore(stat)})
 		} else {
-			log.Println("invalid request, no audio or video config")
-			scores = append(scores, Scores{})
+			results = append(results, Result{Scores: scorer.VideoScore(stat)})
 		}
 	}
+	return results
+}
+
+// Score compute audio and video scores for the passed stats
+//
+// returns audio/video scores for each input, zero scores for invalid inputs (see Evaluate)
+func Score(stats []Stat) []Scores {
+	var scores []Scores
+	for _, result := range Evaluate(stats) {
+		scores = append(scores, result.Scores)
+	}
 	return scores
 }
 
+// normalizeNetworkStat - fills the network defaults and folds the jitter buffer behavior
+// into the packet loss and buffer delay
+func normalizeNetworkStat(input Stat, coefficients *Coefficients) Stat {
+	if input.RoundTripTime == nil {
+		input.RoundTripTime = int32Ptr(DefaultRoundTripTime)
+	}
+
+	if input.BufferDelay == nil {
+		input.BufferDelay = int32Ptr(DefaultBufferDelay)
+	}
+
+	// packets discarded by the jitter buffer never reach the decoder
+	if input.JitterBufferDiscard > 0 {
+		input.PacketLoss += input.JitterBufferDiscard * (100 - input.PacketLoss) / 100
+	}
+
+	// jitter beyond what the buffer absorbs delays the packets on top of the buffer delay, a measured buffer delay
+	// being an average which hides the late packets
+	if excess := float64(input.Jitter) - coefficients.JitterAllowance; excess > 0 {
+		input.BufferDelay = int32Ptr(*input.BufferDelay + int32(math.Round(coefficients.JitterBufferFactor*excess)))
+	}
+
+	return input
+}
+
 func clamp(value, min, max float64) float64 {
 	return math.Max(min, math.Min(value, max))
 }
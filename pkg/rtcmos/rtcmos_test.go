@@ -1,8 +1,10 @@
 package rtcmos
 
 import (
+	"sync"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -515,3 +517,582 @@ func TestScore(t *testing.T) {
 	}
 
 }
+
+func TestVideoPacketLoss(t *testing.T) {
+	{
+		// score of video depends on packet loss
+		stat1 := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  1,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30)},
+		}
+		stat2 := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  5,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30)},
+		}
+		scores := Score([]Stat{stat1, stat2})
+		require.Len(t, scores, 2)
+		require.Greater(t, scores[0].VideoScore, scores[1].VideoScore)
+	}
+	{
+		// score of video is poor with heavy packet loss and no recovery
+		stat := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  20,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), Nack: boolPtr(false)},
+		}
+		scores := Score([]Stat{stat})
+		require.Len(t, scores, 1)
+		require.GreaterOrEqual(t, scores[0].VideoScore, 1.0)
+		require.LessOrEqual(t, scores[0].VideoScore, 2.0)
+	}
+	{
+		// score of video depends on nack
+		stat1 := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  5,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), Nack: boolPtr(true)},
+		}
+		stat2 := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  5,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), Nack: boolPtr(false)},
+		}
+		scores := Score([]Stat{stat1, stat2})
+		require.Len(t, scores, 2)
+		require.Greater(t, scores[0].VideoScore, scores[1].VideoScore)
+	}
+	{
+		// nack is less effective with a high round trip time
+		stat1 := Stat{
+			Bitrate:       1700000,
+			PacketLoss:    5,
+			RoundTripTime: int32Ptr(20),
+			VideoConfig:   &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30)},
+		}
+		stat2 := Stat{
+			Bitrate:       1700000,
+			PacketLoss:    5,
+			RoundTripTime: int32Ptr(300),
+			VideoConfig:   &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30)},
+		}
+		scores := Score([]Stat{stat1, stat2})
+		require.Len(t, scores, 2)
+		require.Greater(t, scores[0].VideoScore, scores[1].VideoScore)
+	}
+	{
+		// score of video depends on fec and red
+		plain := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  5,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), Nack: boolPtr(false)},
+		}
+		fec := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  5,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), Nack: boolPtr(false), Fec: boolPtr(true)},
+		}
+		red := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  5,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), Nack: boolPtr(false), Red: boolPtr(true)},
+		}
+		scores := Score([]Stat{plain, fec, red})
+		require.Len(t, scores, 3)
+		require.Greater(t, scores[1].VideoScore, scores[0].VideoScore)
+		require.Greater(t, scores[2].VideoScore, scores[0].VideoScore)
+	}
+}
+
+func TestAudioBandwidth(t *testing.T) {
+	{
+		// fullband and wideband audio score higher than narrowband in perfect conditions
+		narrowband := Stat{
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			AudioConfig:   &AudioConfig{},
+		}
+		wideband := Stat{
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			AudioConfig:   &AudioConfig{Bandwidth: AudioBandwidthWideband},
+		}
+		fullband := Stat{
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			AudioConfig:   &AudioConfig{Bandwidth: AudioBandwidthFullband},
+		}
+		scores := Score([]Stat{narrowband, wideband, fullband})
+		require.Len(t, scores, 3)
+		t.Log("narrowband", scores[0].AudioScore, "wideband", scores[1].AudioScore, "fullband", scores[2].AudioScore)
+		require.Greater(t, scores[1].AudioScore, scores[0].AudioScore)
+		require.Greater(t, scores[2].AudioScore, scores[1].AudioScore)
+		require.LessOrEqual(t, scores[2].AudioScore, 4.75)
+
+		// R factor on the scale of each bandwidth, Ie of an unknown opus bitrate and 20 ms packetization only
+		require.InDelta(t, 100-6-20*0.03, scores[0].RFactor, 1e-9)
+		require.InDelta(t, (100-6-20*0.03)*1.29, scores[1].RFactor, 1e-9)
+		require.InDelta(t, (100-6-20*0.03)*1.48, scores[2].RFactor, 1e-9)
+	}
+	{
+		// G.107.1 and G.107.2 variants
+		narrowband := Stat{
+			AudioConfig: &AudioConfig{Model: AudioModelG107},
+		}
+		fullband := Stat{
+			AudioConfig: &AudioConfig{Model: AudioModelG107, Bandwidth: AudioBandwidthFullband},
+		}
+		scores := Score([]Stat{narrowband, fullband})
+		require.Len(t, scores, 2)
+		require.Greater(t, scores[1].AudioScore, scores[0].AudioScore)
+	}
+	{
+		// score of fullband audio is 1 in worst conditions
+		stat := Stat{
+			PacketLoss:  100,
+			AudioConfig: &AudioConfig{Bandwidth: AudioBandwidthFullband},
+		}
+		scores := Score([]Stat{stat})
+		require.Len(t, scores, 1)
+		require.GreaterOrEqual(t, scores[0].AudioScore, 1.0)
+		require.LessOrEqual(t, scores[0].AudioScore, 1.2)
+	}
+}
+
+func TestAudioCodec(t *testing.T) {
+	{
+		// G.711 scores well in narrowband but worse than G.722 in wideband
+		pcmu := Stat{
+			Bitrate:     64000,
+			AudioConfig: &AudioConfig{Codec: AudioCodecPCMU},
+		}
+		pcmuWideband := Stat{
+			Bitrate:     64000,
+			AudioConfig: &AudioConfig{Codec: AudioCodecPCMU, Bandwidth: AudioBandwidthWideband},
+		}
+		g722Wideband := Stat{
+			Bitrate:     64000,
+			AudioConfig: &AudioConfig{Codec: AudioCodecG722, Bandwidth: AudioBandwidthWideband},
+		}
+		scores := Score([]Stat{pcmu, pcmuWideband, g722Wideband})
+		require.Len(t, scores, 3)
+		require.GreaterOrEqual(t, scores[0].AudioScore, 4.0)
+		require.Greater(t, scores[2].AudioScore, scores[1].AudioScore)
+	}
+	{
+		// codec names are case insensitive
+		stat1 := Stat{
+			PacketLoss:  5,
+			AudioConfig: &AudioConfig{Codec: "PCMA"},
+		}
+		stat2 := Stat{
+			PacketLoss:  5,
+			AudioConfig: &AudioConfig{Codec: AudioCodecPCMA},
+		}
+		scores := Score([]Stat{stat1, stat2})
+		require.Len(t, scores, 2)
+		require.Equal(t, scores[0].AudioScore, scores[1].AudioScore)
+	}
+	{
+		// score of AMR-WB depends on the bitrate mode
+		stat1 := Stat{
+			Bitrate:     23850,
+			AudioConfig: &AudioConfig{Codec: AudioCodecAMRWB, Bandwidth: AudioBandwidthWideband},
+		}
+		stat2 := Stat{
+			Bitrate:     6600,
+			AudioConfig: &AudioConfig{Codec: AudioCodecAMRWB, Bandwidth: AudioBandwidthWideband},
+		}
+		scores := Score([]Stat{stat1, stat2})
+		require.Len(t, scores, 2)
+		require.Greater(t, scores[0].AudioScore, scores[1].AudioScore)
+	}
+	{
+		// iLBC is more robust to packet loss than G.722
+		ilbc := Stat{
+			PacketLoss:  10,
+			AudioConfig: &AudioConfig{Codec: AudioCodecILBC},
+		}
+		g722 := Stat{
+			PacketLoss:  10,
+			AudioConfig: &AudioConfig{Codec: AudioCodecG722},
+		}
+		scores := Score([]Stat{ilbc, g722})
+		require.Len(t, scores, 2)
+		require.Greater(t, scores[0].AudioScore, scores[1].AudioScore)
+	}
+	{
+		// codecs without impairment values are scored with the opus curve
+		results := Evaluate([]Stat{
+			{Bitrate: 32000, AudioConfig: &AudioConfig{Codec: "g729"}},
+			{Bitrate: 32000, AudioConfig: &AudioConfig{Codec: "OPUS"}},
+		})
+		require.NoError(t, results[0].Err)
+		require.NoError(t, results[1].Err)
+		require.Equal(t, results[1].Scores.AudioScore, results[0].Scores.AudioScore)
+	}
+}
+
+func TestBurstLoss(t *testing.T) {
+	{
+		// random loss has a burst ratio of 1
+		burst := &BurstStat{MeanBurstLength: 1 / (1 - 0.05)}
+		require.InDelta(t, 1.0, burst.burstR(5), 0.0001)
+		require.Equal(t, 1.0, (*BurstStat)(nil).burstR(5))
+	}
+	{
+		// bursts shorter than a packet are rejected
+		results := Evaluate([]Stat{{PacketLoss: 5, Burst: &BurstStat{MeanBurstLength: 0.5}, AudioConfig: &AudioConfig{}}})
+		var validationErr *ValidationError
+		require.ErrorAs(t, results[0].Err, &validationErr)
+		require.Equal(t, "Burst.MeanBurstLength", validationErr.Field)
+
+		// isolated losses (burst ratio below 1) never make the residual video loss negative
+		c := &DefaultCoefficients().Video
+		c.FecRecovery = 1
+		videoConfig := &VideoConfig{Nack: boolPtr(false), Fec: boolPtr(true), Red: boolPtr(false)}
+		isolated := &BurstStat{MeanBurstLength: 1}
+		require.Less(t, isolated.burstR(40), 1.0)
+		residual := residualVideoLoss(40, 0, isolated, videoConfig, c)
+		require.GreaterOrEqual(t, residual, 0.0)
+		require.InDelta(t, 40*0.4, residual, 1e-9)
+	}
+	{
+		// burst ratio grows with the burst density
+		low := &BurstStat{BurstDensity: 30, GapDensity: 1}
+		high := &BurstStat{BurstDensity: 70, GapDensity: 1}
+		require.Greater(t, low.burstR(5), 1.0)
+		require.Greater(t, high.burstR(5), low.burstR(5))
+	}
+	{
+		// bursty audio loss is worse than random loss of the same average
+		random := Stat{
+			PacketLoss:  5,
+			AudioConfig: &AudioConfig{},
+		}
+		bursty := Stat{
+			PacketLoss:  5,
+			Burst:       &BurstStat{MeanBurstLength: 4},
+			AudioConfig: &AudioConfig{},
+		}
+		burstyG107 := Stat{
+			PacketLoss:  5,
+			Burst:       &BurstStat{MeanBurstLength: 4},
+			AudioConfig: &AudioConfig{Model: AudioModelG107},
+		}
+		randomG107 := Stat{
+			PacketLoss:  5,
+			AudioConfig: &AudioConfig{Model: AudioModelG107},
+		}
+		scores := Score([]Stat{random, bursty, randomG107, burstyG107})
+		require.Len(t, scores, 4)
+		require.Greater(t, scores[0].AudioScore, scores[1].AudioScore)
+		require.Greater(t, scores[2].AudioScore, scores[3].AudioScore)
+	}
+	{
+		// bursty video loss defeats RED and is worse than random loss of the same average
+		random := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  5,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), Red: boolPtr(true)},
+		}
+		bursty := Stat{
+			Bitrate:     1700000,
+			PacketLoss:  5,
+			Burst:       &BurstStat{MeanBurstLength: 4},
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), Red: boolPtr(true)},
+		}
+		scores := Score([]Stat{random, bursty})
+		require.Len(t, scores, 2)
+		require.Greater(t, scores[0].VideoScore, scores[1].VideoScore)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	{
+		// score of audio and video depends on jitter
+		audio1 := Stat{
+			Jitter:      5,
+			AudioConfig: &AudioConfig{},
+		}
+		audio2 := Stat{
+			Jitter:      150,
+			AudioConfig: &AudioConfig{},
+		}
+		video1 := Stat{
+			Bitrate:     1700000,
+			Jitter:      5,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30)},
+		}
+		video2 := Stat{
+			Bitrate:     1700000,
+			Jitter:      150,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30)},
+		}
+		scores := Score([]Stat{audio1, audio2, video1, video2})
+		require.Len(t, scores, 4)
+		require.Greater(t, scores[0].AudioScore, scores[1].AudioScore)
+		require.Greater(t, scores[2].VideoScore, scores[3].VideoScore)
+	}
+	{
+		// jitter within the allowance does not change the score
+		stat1 := Stat{
+			BufferDelay: int32Ptr(100),
+			AudioConfig: &AudioConfig{},
+		}
+		stat2 := Stat{
+			BufferDelay: int32Ptr(100),
+			Jitter:      10,
+			AudioConfig: &AudioConfig{},
+		}
+		scores := Score([]Stat{stat1, stat2})
+		require.Len(t, scores, 2)
+		require.Equal(t, scores[0].AudioScore, scores[1].AudioScore)
+	}
+	{
+		// jitter adds to a measured buffer delay, the same average delay scores lower with more jitter
+		low := Stat{
+			BufferDelay: int32Ptr(80),
+			Jitter:      10,
+			AudioConfig: &AudioConfig{},
+		}
+		high := Stat{
+			BufferDelay: int32Ptr(80),
+			Jitter:      40,
+			AudioConfig: &AudioConfig{},
+		}
+		lowVideo := Stat{
+			Bitrate:     1700000,
+			BufferDelay: int32Ptr(80),
+			Jitter:      10,
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30)},
+		}
+		highVideo := lowVideo
+		highVideo.Jitter = 40
+		scores := Score([]Stat{low, high, lowVideo, highVideo})
+		require.Len(t, scores, 4)
+		require.Greater(t, scores[0].AudioScore, scores[1].AudioScore)
+		require.Greater(t, scores[2].VideoScore, scores[3].VideoScore)
+	}
+	{
+		// jitter buffer discards count as packet loss
+		lost := Stat{
+			PacketLoss:  5,
+			AudioConfig: &AudioConfig{},
+		}
+		discarded := Stat{
+			JitterBufferDiscard: 5,
+			AudioConfig:         &AudioConfig{},
+		}
+		both := Stat{
+			PacketLoss:          5,
+			JitterBufferDiscard: 5,
+			AudioConfig:         &AudioConfig{},
+		}
+		scores := Score([]Stat{lost, discarded, both})
+		require.Len(t, scores, 3)
+		require.Equal(t, scores[0].AudioScore, scores[1].AudioScore)
+		require.Greater(t, scores[0].AudioScore, scores[2].AudioScore)
+	}
+}
+
+func TestVideoFreeze(t *testing.T) {
+	{
+		// freezing 2s every 10s is poor even at full frame rate
+		stat := Stat{
+			Bitrate:     1700000,
+			Interval:    int32Ptr(10000),
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), FreezeCount: int32Ptr(1), TotalFreezesDuration: int32Ptr(2000)},
+		}
+		scores := Score([]Stat{stat})
+		require.Len(t, scores, 1)
+		require.GreaterOrEqual(t, scores[0].VideoScore, 1.0)
+		require.LessOrEqual(t, scores[0].VideoScore, 2.0)
+	}
+	{
+		// score of video depends on freeze count and duration
+		smooth := Stat{
+			Bitrate:     1700000,
+			Interval:    int32Ptr(10000),
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30)},
+		}
+		short := Stat{
+			Bitrate:     1700000,
+			Interval:    int32Ptr(10000),
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), FreezeCount: int32Ptr(1), TotalFreezesDuration: int32Ptr(200)},
+		}
+		many := Stat{
+			Bitrate:     1700000,
+			Interval:    int32Ptr(10000),
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), FreezeCount: int32Ptr(5), TotalFreezesDuration: int32Ptr(1000)},
+		}
+		scores := Score([]Stat{smooth, short, many})
+		require.Len(t, scores, 3)
+		require.Greater(t, scores[0].VideoScore, scores[1].VideoScore)
+		require.Greater(t, scores[1].VideoScore, scores[2].VideoScore)
+	}
+	{
+		// pauses are not freezes and are excluded from the active time
+		frozen := Stat{
+			Bitrate:     1700000,
+			Interval:    int32Ptr(10000),
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), FreezeCount: int32Ptr(1), TotalFreezesDuration: int32Ptr(500)},
+		}
+		paused := Stat{
+			Bitrate:     1700000,
+			Interval:    int32Ptr(10000),
+			VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720), FrameRate: float32Ptr(30), FreezeCount: int32Ptr(1), TotalFreezesDuration: int32Ptr(500), TotalPausesDuration: int32Ptr(5000)},
+		}
+		scores := Score([]Stat{frozen, paused})
+		require.Len(t, scores, 2)
+		require.Greater(t, scores[0].VideoScore, scores[1].VideoScore)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	{
+		// valid stats are scored
+		results := Evaluate([]Stat{
+			{AudioConfig: &AudioConfig{}},
+			{Bitrate: 1700000, VideoConfig: &VideoConfig{}},
+		})
+		require.Len(t, results, 2)
+		require.NoError(t, results[0].Err)
+		require.NoError(t, results[1].Err)
+		require.Greater(t, results[0].Scores.AudioScore, 1.0)
+		require.Greater(t, results[1].Scores.VideoScore, 1.0)
+	}
+	{
+		// invalid stats are rejected with the invalid field
+		nan := float32(0)
+		nan = nan / nan
+		invalid := []struct {
+			field string
+			stat  Stat
+		}{
+			{"AudioConfig", Stat{}},
+			{"AudioConfig", Stat{AudioConfig: &AudioConfig{}, VideoConfig: &VideoConfig{}}},
+			{"Bitrate", Stat{Bitrate: -1, AudioConfig: &AudioConfig{}}},
+			{"PacketLoss", Stat{PacketLoss: 101, AudioConfig: &AudioConfig{}}},
+			{"PacketLoss", Stat{PacketLoss: -1, AudioConfig: &AudioConfig{}}},
+			{"PacketLoss", Stat{PacketLoss: nan, AudioConfig: &AudioConfig{}}},
+			{"RoundTripTime", Stat{RoundTripTime: int32Ptr(-1), AudioConfig: &AudioConfig{}}},
+			{"AudioConfig.Model", Stat{AudioConfig: &AudioConfig{Model: "pesq"}}},
+			{"AudioConfig.EModel.Qdu", Stat{AudioConfig: &AudioConfig{Model: AudioModelG107, EModel: &EModelParams{A: 5}}}},
+			{"AudioConfig.EModel.MT", Stat{AudioConfig: &AudioConfig{EModel: &EModelParams{Qdu: 1, ST: 1}}}},
+			{"AudioConfig.EModel.ST", Stat{AudioConfig: &AudioConfig{EModel: &EModelParams{Qdu: 1, MT: 100}}}},
+			{"AudioConfig.EModel.Nc", Stat{AudioConfig: &AudioConfig{EModel: &EModelParams{Qdu: 1, MT: 100, ST: 1, Nc: float64(nan)}}}},
+			{"Burst.MeanBurstLength", Stat{Burst: &BurstStat{MeanBurstLength: 0.5}, AudioConfig: &AudioConfig{}}},
+			{"VideoConfig.Width", Stat{VideoConfig: &VideoConfig{Width: int32Ptr(0)}}},
+			{"VideoConfig.Height", Stat{VideoConfig: &VideoConfig{Height: int32Ptr(-360)}}},
+			{"VideoConfig.FrameRate", Stat{VideoConfig: &VideoConfig{FrameRate: &nan}}},
+			{"VideoConfig.TotalFreezesDuration", Stat{VideoConfig: &VideoConfig{TotalFreezesDuration: int32Ptr(-1)}}},
+		}
+		for _, test := range invalid {
+			results := Evaluate([]Stat{test.stat})
+			require.Len(t, results, 1)
+			var validationErr *ValidationError
+			require.ErrorAs(t, results[0].Err, &validationErr)
+			require.Equal(t, test.field, validationErr.Field)
+			require.Equal(t, Scores{}, results[0].Scores)
+		}
+	}
+	{
+		// Score returns zero scores for invalid stats
+		scores := Score([]Stat{{}, {AudioConfig: &AudioConfig{}}})
+		require.Len(t, scores, 2)
+		require.Equal(t, Scores{}, scores[0])
+		require.Greater(t, scores[1].AudioScore, 1.0)
+	}
+}
+
+func TestScoreDoesNotMutate(t *testing.T) {
+	{
+		// defaults are not written back to the caller's configs
+		audioConfig := &AudioConfig{}
+		videoConfig := &VideoConfig{FrameRate: float32Ptr(30)}
+		Score([]Stat{{AudioConfig: audioConfig}, {Bitrate: 1700000, VideoConfig: videoConfig}})
+		require.Equal(t, &AudioConfig{}, audioConfig)
+		require.Equal(t, &VideoConfig{FrameRate: float32Ptr(30)}, videoConfig)
+	}
+	{
+		// shared configs can be scored concurrently, run with -race
+		audioConfig := &AudioConfig{Model: AudioModelG107}
+		videoConfig := &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720)}
+		rtt := int32Ptr(100)
+		expected := Score([]Stat{
+			{PacketLoss: 1, RoundTripTime: rtt, AudioConfig: audioConfig},
+			{Bitrate: 1700000, RoundTripTime: rtt, VideoConfig: videoConfig},
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					scores := Score([]Stat{
+						{PacketLoss: 1, RoundTripTime: rtt, AudioConfig: audioConfig},
+						{Bitrate: 1700000, RoundTripTime: rtt, VideoConfig: videoConfig},
+					})
+					assert.Equal(t, expected, scores)
+				}
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, &AudioConfig{Model: AudioModelG107}, audioConfig)
+		require.Equal(t, &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720)}, videoConfig)
+	}
+}
+
+func TestAudioImpairments(t *testing.T) {
+	{
+		// simplified model: R0 - Ie-eff - Id
+		stat := Stat{
+			Bitrate:       32000,
+			PacketLoss:    2,
+			RoundTripTime: int32Ptr(200),
+			BufferDelay:   int32Ptr(100),
+			AudioConfig:   &AudioConfig{},
+		}
+		scores := AudioScore(stat)
+		require.Equal(t, 100.0, scores.Impairments.Ro)
+		require.Equal(t, 0.0, scores.Impairments.Is)
+		// 220 ms of delay, 70 ms above the threshold
+		require.InDelta(t, 220*0.03+70*0.1, scores.Impairments.Id, 1e-9)
+		require.Greater(t, scores.Impairments.IeEff, 0.0)
+		require.InDelta(t, scores.Impairments.R(), scores.RFactor, 1e-9)
+		require.Equal(t, mosFromR(scores.RFactor, AudioBandwidthNarrowband), scores.AudioScore)
+
+		// listening quality does not depend on delay, conversational quality does
+		require.Greater(t, scores.AudioListeningScore, scores.AudioScore)
+		stat.RoundTripTime = int32Ptr(0)
+		stat.BufferDelay = int32Ptr(0)
+		require.Equal(t, scores.AudioListeningScore, AudioScore(stat).AudioListeningScore)
+		require.Less(t, scores.AudioScore, AudioScore(stat).AudioScore)
+	}
+	{
+		// G.107 model with wideband scale
+		scores := AudioScore(Stat{AudioConfig: &AudioConfig{Model: AudioModelG107, Bandwidth: AudioBandwidthWideband}})
+		require.Equal(t, 129.0, scores.Impairments.Ro)
+		require.Equal(t, 0.0, scores.Impairments.Is)
+		require.Greater(t, scores.Impairments.Id, 0.0)
+		require.InDelta(t, scores.Impairments.R(), scores.RFactor, 1e-9)
+		require.GreaterOrEqual(t, scores.AudioListeningScore, scores.AudioScore)
+	}
+	{
+		// R is clamped, not the impairments
+		scores := AudioScore(Stat{PacketLoss: 100, RoundTripTime: int32Ptr(2000), AudioConfig: &AudioConfig{Fec: boolPtr(false)}})
+		require.Equal(t, 0.0, scores.RFactor)
+		require.Less(t, scores.Impairments.R(), 0.0)
+		require.Equal(t, 1.0, scores.AudioScore)
+		require.Less(t, scores.AudioListeningScore, 1.5)
+	}
+	{
+		// video has no audio scores
+		scores := VideoScore(Stat{Bitrate: 500000, VideoConfig: &VideoConfig{}})
+		require.Equal(t, EModelImpairments{}, scores.Impairments)
+		require.Equal(t, 0.0, scores.AudioListeningScore)
+	}
+}
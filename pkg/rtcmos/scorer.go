@@ -0,0 +1,113 @@
+package rtcmos
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultScorerName is the name of the built-in model implemented by AudioScore and VideoScore
+const DefaultScorerName = "rtcmos/v1"
+
+var (
+	// ErrScorerNotFound is returned when no scorer is registered with the name
+	ErrScorerNotFound = errors.New("scorer not found")
+	// ErrScorerExists is returned when a scorer is already registered with the name
+	ErrScorerExists = errors.New("scorer already registered")
+)
+
+// Scorer computes the scores of a Stat, implemented by each scoring model
+type Scorer interface {
+	// AudioScore - scores a stat with an AudioConfig
+	AudioScore(stat Stat) Scores
+	// VideoScore - scores a stat with a VideoConfig
+	VideoScore(stat Stat) Scores
+}
+
+// coefficientsScorer - the formulas of AudioScore and VideoScore with a set of coefficients
+type coefficientsScorer struct {
+	coefficients *Coefficients
+}
+
+// NewScorer returns a scorer using the built-in formulas with the given coefficients,
+// DefaultCoefficients when nil. The coefficients must not be modified afterwards.
+func NewScorer(coefficients *Coefficients) Scorer {
+	return coefficientsScorer{coefficients: coefficients}
+}
+
+func (s coefficientsScorer) AudioScore(stat Stat) Scores {
+	return AudioScoreWith(stat, s.coefficients)
+}
+
+func (s coefficientsScorer) VideoScore(stat Stat) Scores {
+	return VideoScoreWith(stat, s.coefficients)
+}
+
+var (
+	scorersLock       sync.RWMutex
+	scorers           = map[string]Scorer{DefaultScorerName: NewScorer(nil)}
+	defaultScorerName = DefaultScorerName
+)
+
+// RegisterScorer makes a scorer available by name, names should carry a version (e.g. "acme/v2")
+// so that historical scores remain reproducible
+func RegisterScorer(name string, scorer Scorer) error {
+	if name == "" || scorer == nil {
+		return errors.New("scorer name and implementation are required")
+	}
+
+	scorersLock.Lock()
+	defer scorersLock.Unlock()
+
+	if _, ok := scorers[name]; ok {
+		return fmt.Errorf("%w: %s", ErrScorerExists, name)
+	}
+	scorers[name] = scorer
+	return nil
+}
+
+// GetScorer returns the scorer registered with the name
+func GetScorer(name string) (Scorer, error) {
+	scorersLock.RLock()
+	defer scorersLock.RUnlock()
+
+	scorer, ok := scorers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrScorerNotFound, name)
+	}
+	return scorer, nil
+}
+
+// ScorerNames returns the names of the registered scorers, sorted
+func ScorerNames() []string {
+	scorersLock.RLock()
+	defer scorersLock.RUnlock()
+
+	names := make([]string, 0, len(scorers))
+	for name := range scorers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDefaultScorer selects the registered scorer used by Score and Evaluate
+func SetDefaultScorer(name string) error {
+	scorersLock.Lock()
+	defer scorersLock.Unlock()
+
+	if _, ok := scorers[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrScorerNotFound, name)
+	}
+	defaultScorerName = name
+	return nil
+}
+
+// DefaultScorer returns the scorer used by Score and Evaluate, and its name
+func DefaultScorer() (string, Scorer) {
+	scorersLock.RLock()
+	defer scorersLock.RUnlock()
+
+	return defaultScorerName, scorers[defaultScorerName]
+}
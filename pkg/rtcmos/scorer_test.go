@@ -0,0 +1,61 @@
+package rtcmos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type constantScorer struct {
+	score float64
+}
+
+func (s constantScorer) AudioScore(stat Stat) Scores {
+	return Scores{AudioScore: s.score}
+}
+
+func (s constantScorer) VideoScore(stat Stat) Scores {
+	return Scores{VideoScore: s.score}
+}
+
+func TestScorerRegistry(t *testing.T) {
+	{
+		// the built-in model is registered and used by default
+		name, scorer := DefaultScorer()
+		require.Equal(t, DefaultScorerName, name)
+		require.Equal(t, AudioScore(Stat{AudioConfig: &AudioConfig{}}), scorer.AudioScore(Stat{AudioConfig: &AudioConfig{}}))
+		require.Contains(t, ScorerNames(), DefaultScorerName)
+	}
+	{
+		// names are unique and must be registered before use
+		require.ErrorIs(t, RegisterScorer(DefaultScorerName, constantScorer{}), ErrScorerExists)
+		require.Error(t, RegisterScorer("", constantScorer{}))
+		require.ErrorIs(t, SetDefaultScorer("unknown/v1"), ErrScorerNotFound)
+		_, err := GetScorer("unknown/v1")
+		require.ErrorIs(t, err, ErrScorerNotFound)
+	}
+	{
+		// Score delegates to the configured scorer
+		require.NoError(t, RegisterScorer("test/constant", constantScorer{score: 3}))
+		scorer, err := GetScorer("test/constant")
+		require.NoError(t, err)
+		require.Equal(t, 3.0, scorer.VideoScore(Stat{}).VideoScore)
+
+		require.NoError(t, SetDefaultScorer("test/constant"))
+		defer func() {
+			require.NoError(t, SetDefaultScorer(DefaultScorerName))
+		}()
+		scores := Score([]Stat{{AudioConfig: &AudioConfig{}}, {VideoConfig: &VideoConfig{}}})
+		require.Equal(t, []Scores{{AudioScore: 3}, {VideoScore: 3}}, scores)
+
+		// stats are still validated before reaching the scorer
+		results := Evaluate([]Stat{{}})
+		require.Error(t, results[0].Err)
+	}
+	{
+		// a scorer can be used without making it the default
+		results := EvaluateWith(constantScorer{score: 2}, []Stat{{AudioConfig: &AudioConfig{}}})
+		require.Len(t, results, 1)
+		require.Equal(t, 2.0, results[0].Scores.AudioScore)
+	}
+}
@@ -0,0 +1,159 @@
+package rtcmos
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// MinSolveBitrate, MaxSolveBitrate: range of bitrates (bps) searched by RequiredBitrate
+	MinSolveBitrate = 1000
+	MaxSolveBitrate = 100000000
+)
+
+// ErrTargetUnreachable is returned when no bitrate reaches the target score
+var ErrTargetUnreachable = errors.New("target score cannot be reached")
+
+// RequiredBitrate returns the minimum bitrate (bps) at which the stat reaches the target score with the default
+// scorer, see RequiredBitrateWith
+func RequiredBitrate(stat Stat, target float64) (float32, error) {
+	_, scorer := DefaultScorer()
+	return RequiredBitrateWith(scorer, stat, target)
+}
+
+// RequiredBitrateWith returns the minimum bitrate (bps) at which the stat reaches the target score with the given
+// scorer, the audio score for a stat with an AudioConfig and the video score otherwise. The bitrate of the stat is
+// ignored, the other fields (resolution, frame rate, codec, network conditions) are kept.
+//
+// For audio codecs with a fixed set of modes (e.g. G.722, AMR-WB), the lowest mode bitrate reaching the target is
+// returned. Otherwise the score must not decrease with the bitrate, the bitrate is searched between MinSolveBitrate
+// and MaxSolveBitrate to 0.1%. Returns ErrTargetUnreachable when the target is not reached by the highest mode or
+// at MaxSolveBitrate.
+func RequiredBitrateWith(scorer Scorer, stat Stat, target float64) (float32, error) {
+	stat.Bitrate = MaxSolveBitrate
+	if err := stat.Validate(); err != nil {
+		return 0, err
+	}
+	score := func(bitrate float64) float64 {
+		stat.Bitrate = float32(bitrate)
+		if stat.AudioConfig != nil {
+			return scorer.AudioScore(stat).AudioScore
+		}
+		return scorer.VideoScore(stat).VideoScore
+	}
+
+	if stat.AudioConfig != nil {
+		if bitrates, ok := codecBitrates(stat.AudioConfig.Codec); ok {
+			for _, bitrate := range bitrates {
+				if score(float64(bitrate)) >= target {
+					return bitrate, nil
+				}
+			}
+			best := bitrates[len(bitrates)-1]
+			return 0, fmt.Errorf("%w: %.2f with the %.0f bps mode of %s", ErrTargetUnreachable, score(float64(best)), best, stat.AudioConfig.Codec)
+		}
+	}
+
+	low, high := float64(MinSolveBitrate), float64(MaxSolveBitrate)
+	if best := score(high); best < target {
+		return 0, fmt.Errorf("%w: %.2f at %d bps", ErrTargetUnreachable, best, MaxSolveBitrate)
+	}
+	if score(low) >= target {
+		return float32(low), nil
+	}
+	// bisection on a log scale, the score at high reaches the target
+	for high/low > 1.001 {
+		middle := math.Sqrt(low * high)
+		if score(middle) >= target {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+	return float32(math.Ceil(high)), nil
+}
+
+// VideoLayer is a resolution and frame rate an encoder can produce
+type VideoLayer struct {
+	Width     int32
+	Height    int32
+	FrameRate float32
+}
+
+// LayerPlan is a video layer with the bitrate it requires
+type LayerPlan struct {
+	Layer VideoLayer
+	// Bitrate: minimum bitrate of the layer reaching the target (bps)
+	Bitrate float32
+	// Scores: scores of the layer at the bitrate
+	Scores Scores
+}
+
+// BestVideoLayer returns the best layer reaching the target score under the bitrate cap with the default scorer,
+// see BestVideoLayerWith
+func BestVideoLayer(stat Stat, layers []VideoLayer, bitrateCap float32, target float64) (LayerPlan, error) {
+	_, scorer := DefaultScorer()
+	return BestVideoLayerWith(scorer, stat, layers, bitrateCap, target)
+}
+
+// BestVideoLayerWith returns the best layer reaching the target score under the bitrate cap with the given scorer,
+// the stat provides the codec, the expected frame rate and the network conditions.
+//
+// Layers are scored against the same expected frame rate, the highest frame rate of the layers when the stat does not
+// set it, so that a low frame rate layer pays its frame rate penalty and only reaches the target with more bitrate.
+// The video score rates a layer at its own resolution and cannot rank layers of different resolutions, so the most
+// pixels per second is used as a proxy: the best layer is the one with the most pixels per second among the layers
+// reaching the target within the cap, the one requiring the lowest bitrate on ties.
+// Returns ErrTargetUnreachable when no layer reaches the target within the cap.
+func BestVideoLayerWith(scorer Scorer, stat Stat, layers []VideoLayer, bitrateCap float32, target float64) (LayerPlan, error) {
+	if stat.VideoConfig == nil {
+		return LayerPlan{}, &ValidationError{Field: "VideoConfig", Reason: "is required"}
+	}
+
+	expectedFrameRate := stat.VideoConfig.ExpectedFrameRate
+	if expectedFrameRate == nil {
+		var highest float32
+		for _, layer := range layers {
+			if layer.FrameRate > highest {
+				highest = layer.FrameRate
+			}
+		}
+		expectedFrameRate = float32Ptr(highest)
+	}
+
+	var best *LayerPlan
+	for _, layer := range layers {
+		videoConfig := *stat.VideoConfig
+		videoConfig.Width = int32Ptr(layer.Width)
+		videoConfig.Height = int32Ptr(layer.Height)
+		videoConfig.FrameRate = float32Ptr(layer.FrameRate)
+		videoConfig.ExpectedFrameRate = expectedFrameRate
+		candidate := stat
+		candidate.VideoConfig = &videoConfig
+
+		bitrate, err := RequiredBitrateWith(scorer, candidate, target)
+		if errors.Is(err, ErrTargetUnreachable) || bitrate > bitrateCap {
+			continue
+		}
+		if err != nil {
+			return LayerPlan{}, err
+		}
+
+		candidate.Bitrate = bitrate
+		plan := LayerPlan{Layer: layer, Bitrate: bitrate, Scores: scorer.VideoScore(candidate)}
+		if best == nil || layer.pixelRate() > best.Layer.pixelRate() ||
+			(layer.pixelRate() == best.Layer.pixelRate() && bitrate < best.Bitrate) {
+			best = &plan
+		}
+	}
+	if best == nil {
+		return LayerPlan{}, fmt.Errorf("%w: no layer within %.0f bps", ErrTargetUnreachable, bitrateCap)
+	}
+	return *best, nil
+}
+
+// pixelRate - pixels per second
+func (l VideoLayer) pixelRate() float64 {
+	return float64(l.Width) * float64(l.Height) * float64(l.FrameRate)
+}
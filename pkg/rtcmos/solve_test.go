@@ -0,0 +1,155 @@
+package rtcmos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredBitrate(t *testing.T) {
+	{
+		// video: the required bitrate reaches the target, slightly less does not
+		stat := Stat{
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			VideoConfig: &VideoConfig{
+				Codec:     "vp8",
+				Width:     int32Ptr(1280),
+				Height:    int32Ptr(720),
+				FrameRate: float32Ptr(30),
+			},
+		}
+		bitrate, err := RequiredBitrate(stat, 3.8)
+		require.NoError(t, err)
+		require.InDelta(t, 1700000, bitrate, 100000)
+
+		stat.Bitrate = bitrate
+		require.GreaterOrEqual(t, VideoScore(stat).VideoScore, 3.8)
+		stat.Bitrate = bitrate * 0.99
+		require.Less(t, VideoScore(stat).VideoScore, 3.8)
+
+		// a more efficient codec needs less bitrate
+		stat.VideoConfig.Codec = "av1"
+		av1, err := RequiredBitrate(stat, 3.8)
+		require.NoError(t, err)
+		require.InDelta(t, float64(bitrate)/1.43, av1, float64(bitrate)*0.01)
+	}
+
+	{
+		// audio: opus reaches the target at a moderate bitrate
+		stat := Stat{
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			AudioConfig:   &AudioConfig{},
+		}
+		bitrate, err := RequiredBitrate(stat, 4)
+		require.NoError(t, err)
+		stat.Bitrate = bitrate
+		require.GreaterOrEqual(t, AudioScore(stat).AudioScore, 4.0)
+		stat.Bitrate = bitrate * 0.99
+		require.Less(t, AudioScore(stat).AudioScore, 4.0)
+
+		// loss cannot be compensated with bitrate
+		stat.PacketLoss = 30
+		_, err = RequiredBitrate(stat, 4)
+		require.True(t, errors.Is(err, ErrTargetUnreachable))
+	}
+
+	{
+		// codecs with fixed modes return the lowest mode reaching the target
+		g722 := Stat{
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			AudioConfig:   &AudioConfig{Codec: AudioCodecG722, Bandwidth: AudioBandwidthWideband},
+		}
+		bitrate, err := RequiredBitrate(g722, 3.9)
+		require.NoError(t, err)
+		require.Equal(t, float32(48000), bitrate)
+		bitrate, err = RequiredBitrate(g722, 4.3)
+		require.NoError(t, err)
+		require.Equal(t, float32(56000), bitrate)
+		_, err = RequiredBitrate(g722, 4.6)
+		require.True(t, errors.Is(err, ErrTargetUnreachable))
+
+		amrwb := g722
+		amrwb.AudioConfig = &AudioConfig{Codec: AudioCodecAMRWB, Bandwidth: AudioBandwidthWideband}
+		bitrate, err = RequiredBitrate(amrwb, 3.5)
+		require.NoError(t, err)
+		require.Equal(t, float32(6600), bitrate)
+		bitrate, err = RequiredBitrate(amrwb, 4)
+		require.NoError(t, err)
+		require.Equal(t, float32(8850), bitrate)
+		_, err = RequiredBitrate(amrwb, 4.8)
+		require.True(t, errors.Is(err, ErrTargetUnreachable))
+	}
+
+	{
+		// invalid stat
+		_, err := RequiredBitrate(Stat{PacketLoss: -1, VideoConfig: &VideoConfig{}}, 3)
+		var validationError *ValidationError
+		require.True(t, errors.As(err, &validationError))
+	}
+}
+
+func TestBestVideoLayer(t *testing.T) {
+	stat := Stat{
+		RoundTripTime: int32Ptr(0),
+		BufferDelay:   int32Ptr(0),
+		VideoConfig:   &VideoConfig{Codec: "vp8", ExpectedFrameRate: float32Ptr(30)},
+	}
+	layers := []VideoLayer{
+		{Width: 320, Height: 180, FrameRate: 30},
+		{Width: 640, Height: 360, FrameRate: 30},
+		{Width: 640, Height: 360, FrameRate: 15},
+		{Width: 1280, Height: 720, FrameRate: 30},
+	}
+
+	{
+		// the cap allows 720p
+		plan, err := BestVideoLayer(stat, layers, 2500000, 3.5)
+		require.NoError(t, err)
+		require.Equal(t, layers[3], plan.Layer)
+		require.LessOrEqual(t, plan.Bitrate, float32(2500000))
+		require.GreaterOrEqual(t, plan.Scores.VideoScore, 3.5)
+	}
+
+	{
+		// a lower cap falls back to 360p at the full frame rate
+		plan, err := BestVideoLayer(stat, layers, 600000, 3.5)
+		require.NoError(t, err)
+		require.Equal(t, layers[1], plan.Layer)
+	}
+
+	{
+		// nothing fits
+		_, err := BestVideoLayer(stat, layers, 10000, 3.5)
+		require.True(t, errors.Is(err, ErrTargetUnreachable))
+
+		_, err = BestVideoLayer(Stat{}, layers, 2500000, 3.5)
+		require.Error(t, err)
+	}
+
+	{
+		// without expected frame rate, low frame rate layers are scored against the highest frame rate of the layers
+		stat := Stat{
+			RoundTripTime: int32Ptr(0),
+			BufferDelay:   int32Ptr(0),
+			VideoConfig:   &VideoConfig{Codec: "vp8"},
+		}
+		layers := []VideoLayer{
+			{Width: 1920, Height: 1080, FrameRate: 5},
+			{Width: 1280, Height: 720, FrameRate: 7.5},
+			{Width: 1280, Height: 720, FrameRate: 30},
+			{Width: 640, Height: 360, FrameRate: 30},
+		}
+		plan, err := BestVideoLayer(stat, layers, 10000000, 3.5)
+		require.NoError(t, err)
+		require.Equal(t, layers[2], plan.Layer)
+
+		plan, err = BestVideoLayer(stat, layers, 600000, 3.5)
+		require.NoError(t, err)
+		require.Equal(t, layers[3], plan.Layer)
+		require.Nil(t, stat.VideoConfig.ExpectedFrameRate)
+	}
+}
@@ -0,0 +1,214 @@
+package rtcmos
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// ErrOutOfOrder is returned for a sample older than the latest one of the track
+var ErrOutOfOrder = errors.New("sample is out of order")
+
+// TrackScorer scores the stats of a track over time.
+//
+// Besides the score of the latest sample, it keeps the scores of a sliding window for the min, max and percentiles,
+// and exponentially weighted moving averages (EWMA) of both the inputs and the scores so that a single bad sample
+// does not make the score jump. The averages are weighted by time, a sample weighs half as much after HalfLife.
+type TrackScorer struct {
+	// Scorer: scorer used, the default scorer when nil
+	Scorer Scorer
+	// Window: duration of the sliding window (ms), only the latest sample is kept when 0
+	Window float64
+	// HalfLife: half life of the moving averages (ms), no smoothing when 0
+	HalfLife float64
+
+	samples   []trackSample
+	last      *float64
+	smoothed  float64
+	inputs    smoothedInputs
+	latest    Stat
+	hasScores bool
+}
+
+// trackSample - score of a sample in the window
+type trackSample struct {
+	timestamp float64
+	score     float64
+}
+
+// smoothedInputs - moving averages of the network inputs of the samples
+type smoothedInputs struct {
+	packetLoss          float64
+	bitrate             float64
+	jitter              float64
+	jitterBufferDiscard float64
+	roundTripTime       *float64
+	bufferDelay         *float64
+	frameRate           *float64
+}
+
+// TrackScores contains the scores of a track, the audio score for audio tracks and the video score otherwise
+type TrackScores struct {
+	// Current: score of the latest sample
+	Current float64
+	// Smoothed: moving average of the scores
+	Smoothed float64
+	// SmoothedInputs: score of the moving averages of the inputs
+	SmoothedInputs float64
+	// Min, Max: lowest and highest scores of the window
+	Min float64
+	Max float64
+	// Samples: number of samples in the window
+	Samples int
+}
+
+// NewTrackScorer returns a TrackScorer using the default scorer with the given window and half life (ms)
+func NewTrackScorer(window, halfLife float64) *TrackScorer {
+	return &TrackScorer{Window: window, HalfLife: halfLife}
+}
+
+// Add scores a sample taken at the timestamp (ms), returns a *ValidationError for an invalid stat and ErrOutOfOrder
+// for a sample not newer than the latest one. Rejected samples leave the track unchanged.
+func (t *TrackScorer) Add(timestamp float64, stat Stat) (Scores, error) {
+	if err := stat.Validate(); err != nil {
+		return Scores{}, err
+	}
+	if t.last != nil && timestamp <= *t.last {
+		return Scores{}, ErrOutOfOrder
+	}
+
+	scores := t.score(stat)
+	score := trackScore(stat, scores)
+
+	// weight of the new sample in the moving averages
+	weight := 1.0
+	if t.last != nil && t.HalfLife > 0 {
+		weight = 1 - math.Pow(2, -(timestamp-*t.last)/t.HalfLife)
+	}
+	t.smoothed = ewma(t.smoothed, score, weight)
+	t.inputs.add(stat, weight)
+	t.latest = stat
+	t.last = &timestamp
+	t.hasScores = true
+
+	t.samples = append(t.samples, trackSample{timestamp: timestamp, score: score})
+	start := 0
+	for start < len(t.samples)-1 && t.samples[start].timestamp <= timestamp-t.Window {
+		start++
+	}
+	t.samples = append(t.samples[:0], t.samples[start:]...)
+
+	return scores, nil
+}
+
+// Scores returns the scores of the track, false before the first sample
+func (t *TrackScorer) Scores() (TrackScores, bool) {
+	if !t.hasScores {
+		return TrackScores{}, false
+	}
+
+	scores := TrackScores{
+		Current:        t.samples[len(t.samples)-1].score,
+		Smoothed:       t.smoothed,
+		SmoothedInputs: trackScore(t.latest, t.score(t.inputs.stat(t.latest))),
+		Min:            math.Inf(1),
+		Max:            math.Inf(-1),
+		Samples:        len(t.samples),
+	}
+	for _, sample := range t.samples {
+		scores.Min = math.Min(scores.Min, sample.score)
+		scores.Max = math.Max(scores.Max, sample.score)
+	}
+	return scores, true
+}
+
+// Percentile returns the nearest rank percentile (0 - 100) of the scores of the window, 0 before the first sample
+func (t *TrackScorer) Percentile(percentile float64) float64 {
+	if len(t.samples) == 0 {
+		return 0
+	}
+
+	scores := make([]float64, 0, len(t.samples))
+	for _, sample := range t.samples {
+		scores = append(scores, sample.score)
+	}
+	sort.Float64s(scores)
+
+	rank := int(math.Ceil(clamp(percentile, 0, 100) / 100 * float64(len(scores))))
+	if rank < 1 {
+		rank = 1
+	}
+	return scores[rank-1]
+}
+
+// score - scores of the stat with the scorer of the track
+func (t *TrackScorer) score(stat Stat) Scores {
+	scorer := t.Scorer
+	if scorer == nil {
+		_, scorer = DefaultScorer()
+	}
+	if stat.AudioConfig != nil {
+		return scorer.AudioScore(stat)
+	}
+	return scorer.VideoScore(stat)
+}
+
+// trackScore - audio score of audio stats, video score otherwise
+func trackScore(stat Stat, scores Scores) float64 {
+	if stat.AudioConfig != nil {
+		return scores.AudioScore
+	}
+	return scores.VideoScore
+}
+
+// add - moves the averages towards the inputs of the stat, missing optional inputs keep their average
+func (s *smoothedInputs) add(stat Stat, weight float64) {
+	s.packetLoss = ewma(s.packetLoss, float64(stat.PacketLoss), weight)
+	s.bitrate = ewma(s.bitrate, float64(stat.Bitrate), weight)
+	s.jitter = ewma(s.jitter, float64(stat.Jitter), weight)
+	s.jitterBufferDiscard = ewma(s.jitterBufferDiscard, float64(stat.JitterBufferDiscard), weight)
+	if stat.RoundTripTime != nil {
+		s.roundTripTime = ewmaPtr(s.roundTripTime, float64(*stat.RoundTripTime), weight)
+	}
+	if stat.BufferDelay != nil {
+		s.bufferDelay = ewmaPtr(s.bufferDelay, float64(*stat.BufferDelay), weight)
+	}
+	if stat.VideoConfig != nil && stat.VideoConfig.FrameRate != nil {
+		s.frameRate = ewmaPtr(s.frameRate, float64(*stat.VideoConfig.FrameRate), weight)
+	}
+}
+
+// stat - the latest stat with the averaged inputs
+func (s *smoothedInputs) stat(latest Stat) Stat {
+	stat := latest
+	stat.PacketLoss = float32(s.packetLoss)
+	stat.Bitrate = float32(s.bitrate)
+	stat.Jitter = float32(s.jitter)
+	stat.JitterBufferDiscard = float32(s.jitterBufferDiscard)
+	if s.roundTripTime != nil {
+		stat.RoundTripTime = int32Ptr(int32(math.Round(*s.roundTripTime)))
+	}
+	if s.bufferDelay != nil {
+		stat.BufferDelay = int32Ptr(int32(math.Round(*s.bufferDelay)))
+	}
+	if stat.VideoConfig != nil && s.frameRate != nil {
+		videoConfig := *stat.VideoConfig
+		videoConfig.FrameRate = float32Ptr(float32(*s.frameRate))
+		stat.VideoConfig = &videoConfig
+	}
+	return stat
+}
+
+// ewma - moves the average towards the value by the weight of the value
+func ewma(average, value, weight float64) float64 {
+	return average + weight*(value-average)
+}
+
+// ewmaPtr - ewma starting from the value when there is no average yet
+func ewmaPtr(average *float64, value, weight float64) *float64 {
+	if average == nil {
+		return &value
+	}
+	result := ewma(*average, value, weight)
+	return &result
+}
@@ -0,0 +1,103 @@
+package rtcmos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackScorer(t *testing.T) {
+	good := Stat{
+		Bitrate:       64000,
+		RoundTripTime: int32Ptr(50),
+		BufferDelay:   int32Ptr(20),
+		AudioConfig:   &AudioConfig{},
+	}
+	bad := good
+	bad.PacketLoss = 20
+	goodScore := AudioScore(good).AudioScore
+	badScore := AudioScore(bad).AudioScore
+
+	{
+		// no samples
+		track := NewTrackScorer(10000, 3000)
+		_, ok := track.Scores()
+		require.False(t, ok)
+		require.Equal(t, 0.0, track.Percentile(50))
+	}
+
+	{
+		// a single bad sample moves the averages by its weight only
+		track := NewTrackScorer(10000, 3000)
+		for i := 0; i < 10; i++ {
+			_, err := track.Add(float64(i*1000), good)
+			require.NoError(t, err)
+		}
+		scores, err := track.Add(10000, bad)
+		require.NoError(t, err)
+		require.Equal(t, AudioScore(bad), scores)
+
+		trackScores, ok := track.Scores()
+		require.True(t, ok)
+		require.Equal(t, badScore, trackScores.Current)
+		require.Equal(t, badScore, trackScores.Min)
+		require.Equal(t, goodScore, trackScores.Max)
+		// the first sample left the window
+		require.Equal(t, 10, trackScores.Samples)
+		// weight of a sample after a second with a half life of 3 seconds is about 0.2
+		require.InDelta(t, goodScore-0.206*(goodScore-badScore), trackScores.Smoothed, 1e-3)
+		require.Greater(t, trackScores.SmoothedInputs, badScore)
+		require.Less(t, trackScores.SmoothedInputs, goodScore)
+
+		require.Equal(t, badScore, track.Percentile(0))
+		require.Equal(t, badScore, track.Percentile(10))
+		require.Equal(t, goodScore, track.Percentile(11))
+		require.Equal(t, goodScore, track.Percentile(100))
+	}
+
+	{
+		// without smoothing nor window, only the latest sample counts
+		track := NewTrackScorer(0, 0)
+		_, err := track.Add(0, good)
+		require.NoError(t, err)
+		_, err = track.Add(1000, bad)
+		require.NoError(t, err)
+		trackScores, _ := track.Scores()
+		require.Equal(t, TrackScores{
+			Current:        badScore,
+			Smoothed:       badScore,
+			SmoothedInputs: badScore,
+			Min:            badScore,
+			Max:            badScore,
+			Samples:        1,
+		}, trackScores)
+	}
+
+	{
+		// rejected samples leave the track unchanged
+		track := NewTrackScorer(10000, 3000)
+		_, err := track.Add(1000, good)
+		require.NoError(t, err)
+		_, err = track.Add(1000, bad)
+		require.True(t, errors.Is(err, ErrOutOfOrder))
+		_, err = track.Add(2000, Stat{PacketLoss: 200, AudioConfig: &AudioConfig{}})
+		var validationError *ValidationError
+		require.True(t, errors.As(err, &validationError))
+
+		trackScores, _ := track.Scores()
+		require.Equal(t, goodScore, trackScores.Min)
+		require.Equal(t, 1, trackScores.Samples)
+	}
+
+	{
+		// video with a custom scorer
+		track := &TrackScorer{Scorer: NewScorer(DefaultCoefficients()), Window: 5000}
+		stat := Stat{Bitrate: 1700000, VideoConfig: &VideoConfig{Width: int32Ptr(1280), Height: int32Ptr(720)}}
+		_, err := track.Add(0, stat)
+		require.NoError(t, err)
+		trackScores, _ := track.Scores()
+		require.Equal(t, VideoScore(stat).VideoScore, trackScores.Current)
+		require.Equal(t, VideoScore(stat).VideoScore, trackScores.SmoothedInputs)
+	}
+}
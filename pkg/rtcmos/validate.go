@@ -0,0 +1,170 @@
+package rtcmos
+
+import (
+	"fmt"
+	"math"
+)
+
+// ValidationError is returned for a Stat which cannot be scored
+type ValidationError struct {
+	// Field: name of the invalid field
+	Field string
+	// Reason: why the field is invalid
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// Validate checks that the stat can be scored, returns a *ValidationError otherwise
+func (s Stat) Validate() error {
+	if s.AudioConfig == nil && s.VideoConfig == nil {
+		return &ValidationError{Field: "AudioConfig", Reason: "no audio or video config"}
+	}
+	if s.AudioConfig != nil && s.VideoConfig != nil {
+		return &ValidationError{Field: "AudioConfig", Reason: "both audio and video config"}
+	}
+
+	if err := validatePercentage("PacketLoss", s.PacketLoss); err != nil {
+		return err
+	}
+	if err := validateNonNegative("Bitrate", s.Bitrate); err != nil {
+		return err
+	}
+	if err := validateNonNegativeInt("RoundTripTime", s.RoundTripTime); err != nil {
+		return err
+	}
+	if err := validateNonNegativeInt("BufferDelay", s.BufferDelay); err != nil {
+		return err
+	}
+	if s.Interval != nil && *s.Interval <= 0 {
+		return &ValidationError{Field: "Interval", Reason: "must be positive"}
+	}
+	if err := validateNonNegative("Jitter", s.Jitter); err != nil {
+		return err
+	}
+	if err := validatePercentage("JitterBufferDiscard", s.JitterBufferDiscard); err != nil {
+		return err
+	}
+	if s.Burst != nil {
+		if err := validateNonNegative("Burst.MeanBurstLength", s.Burst.MeanBurstLength); err != nil {
+			return err
+		}
+		// a burst holds at least one packet, 0 when not reported
+		if s.Burst.MeanBurstLength > 0 && s.Burst.MeanBurstLength < 1 {
+			return &ValidationError{Field: "Burst.MeanBurstLength", Reason: "must be at least 1 when set"}
+		}
+		if err := validatePercentage("Burst.BurstDensity", s.Burst.BurstDensity); err != nil {
+			return err
+		}
+		if err := validatePercentage("Burst.GapDensity", s.Burst.GapDensity); err != nil {
+			return err
+		}
+	}
+
+	if s.AudioConfig != nil {
+		return s.AudioConfig.validate()
+	}
+	return s.VideoConfig.validate()
+}
+
+func (c *AudioConfig) validate() error {
+	switch c.Model {
+	case "", AudioModelSimplified, AudioModelG107:
+	default:
+		return &ValidationError{Field: "AudioConfig.Model", Reason: fmt.Sprintf("unknown model %q", c.Model)}
+	}
+
+	switch c.Bandwidth {
+	case "", AudioBandwidthNarrowband, AudioBandwidthWideband, AudioBandwidthFullband:
+	default:
+		return &ValidationError{Field: "AudioConfig.Bandwidth", Reason: fmt.Sprintf("unknown bandwidth %q", c.Bandwidth)}
+	}
+
+	if c.EModel != nil {
+		return c.EModel.validate()
+	}
+	return nil
+}
+
+// validate - the G.107 formulas need finite parameters and take the logarithm of Qdu and mT and divide by sT
+func (p *EModelParams) validate() error {
+	params := []struct {
+		field string
+		value float64
+	}{
+		{"SLR", p.SLR}, {"RLR", p.RLR}, {"STMR", p.STMR}, {"LSTR", p.LSTR}, {"Ds", p.Ds}, {"Dr", p.Dr},
+		{"TELR", p.TELR}, {"WEPL", p.WEPL}, {"Qdu", p.Qdu}, {"Nc", p.Nc}, {"Nfor", p.Nfor}, {"Ps", p.Ps},
+		{"Pr", p.Pr}, {"A", p.A}, {"MT", p.MT}, {"ST", p.ST},
+	}
+	for _, param := range params {
+		if math.IsNaN(param.value) || math.IsInf(param.value, 0) {
+			return &ValidationError{Field: "AudioConfig.EModel." + param.field, Reason: "must be a finite number"}
+		}
+	}
+
+	if p.Qdu <= 0 {
+		return &ValidationError{Field: "AudioConfig.EModel.Qdu", Reason: "must be positive"}
+	}
+	if p.MT <= 0 {
+		return &ValidationError{Field: "AudioConfig.EModel.MT", Reason: "must be positive"}
+	}
+	if p.ST <= 0 {
+		return &ValidationError{Field: "AudioConfig.EModel.ST", Reason: "must be positive"}
+	}
+	return nil
+}
+
+func (c *VideoConfig) validate() error {
+	if c.Width != nil && *c.Width <= 0 {
+		return &ValidationError{Field: "VideoConfig.Width", Reason: "must be positive"}
+	}
+	if c.Height != nil && *c.Height <= 0 {
+		return &ValidationError{Field: "VideoConfig.Height", Reason: "must be positive"}
+	}
+	if c.FrameRate != nil {
+		if err := validateNonNegative("VideoConfig.FrameRate", *c.FrameRate); err != nil {
+			return err
+		}
+	}
+	if c.ExpectedFrameRate != nil {
+		if err := validateNonNegative("VideoConfig.ExpectedFrameRate", *c.ExpectedFrameRate); err != nil {
+			return err
+		}
+	}
+	if err := validateNonNegativeInt("VideoConfig.FreezeCount", c.FreezeCount); err != nil {
+		return err
+	}
+	if err := validateNonNegativeInt("VideoConfig.TotalFreezesDuration", c.TotalFreezesDuration); err != nil {
+		return err
+	}
+	return validateNonNegativeInt("VideoConfig.TotalPausesDuration", c.TotalPausesDuration)
+}
+
+func validateNonNegative(field string, value float32) error {
+	if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+		return &ValidationError{Field: field, Reason: "must be a finite number"}
+	}
+	if value < 0 {
+		return &ValidationError{Field: field, Reason: "must not be negative"}
+	}
+	return nil
+}
+
+func validatePercentage(field string, value float32) error {
+	if err := validateNonNegative(field, value); err != nil {
+		return err
+	}
+	if value > 100 {
+		return &ValidationError{Field: field, Reason: "must be a percentage between 0 and 100"}
+	}
+	return nil
+}
+
+func validateNonNegativeInt(field string, value *int32) error {
+	if value != nil && *value < 0 {
+		return &ValidationError{Field: field, Reason: "must not be negative"}
+	}
+	return nil
+}
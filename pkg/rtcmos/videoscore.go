@@ -2,7 +2,6 @@ package rtcmos
 
 import (
 	"math"
-	"strings"
 )
 
 const (
@@ -23,69 +22,162 @@ type VideoConfig struct {
 	FrameRate *float32
 	// ExpectedFrameRate: FrameRate of the video source
 	ExpectedFrameRate *float32
+	// Nack: flag to pass NACK retransmission status
+	Nack *bool
+	// Fec: flag to pass FlexFEC / ULPFEC forward error correction status
+	Fec *bool
+	// Red: flag to pass RED (Redundant Encoding) for video enabled
+	Red *bool
+	// FreezeCount: number of freezes during the interval
+	FreezeCount *int32
+	// TotalFreezesDuration: time spent frozen during the interval (ms)
+	TotalFreezesDuration *int32
+	// TotalPausesDuration: time spent paused (no frames sent on purpose) during the interval (ms)
+	TotalPausesDuration *int32
 }
 
 // VideoScore - MOS calculation based on logarithmic regression
 func VideoScore(input Stat) Scores {
-	stat := normalizeVideoStat(input)
-	videoConfig := stat.VideoConfig
-	if videoConfig == nil {
+	return VideoScoreWith(input, nil)
+}
+
+// VideoScoreWith - VideoScore with the given coefficients, DefaultCoefficients when nil
+func VideoScoreWith(input Stat, coefficients *Coefficients) Scores {
+	if input.VideoConfig == nil {
 		return Scores{}
 	}
-	codecFactor := 1.0
-	if strings.ToLower(videoConfig.Codec) == "vp9" {
-		// assuming approximately 83% of vp8/h.264 bitrate for same quality
-		codecFactor = 1.2
-	}
-	if strings.ToLower(videoConfig.Codec) == "av1" {
-		// assuming approximately 70% of vp8/h.264 bitrate for same quality
-		codecFactor = 1.43
-	}
+	c := &coefficients.orDefault().Video
 
-	delay := float64(*stat.BufferDelay + *stat.RoundTripTime/2)
+	features, ok := ExtractVideoFeatures(input, coefficients)
+	if !ok {
+		return Scores{VideoScore: 1}
+	}
 
-	var score Scores
 	// These parameters are generated with a logarithmic regression
 	// on some very limited test data for now
 	// They are based on the bits per pixel per frame (bPPPF)
-	if *videoConfig.FrameRate != 0 {
-		frameRate := float64(*videoConfig.FrameRate)
-		pixels := float64(*videoConfig.Width * *videoConfig.Height)
-		bPPPF := (codecFactor * float64(stat.Bitrate)) / pixels / frameRate
-
-		//
-		// A bit of speculation on logarithmic regression equation from https://github.com/ggarber/rtcscore
-		// base := clamp(0.56*math.Log(bPPPF)+5.36, 1, 5)
-		//
-		// Assuming that derivation is based on Chrome (libwebrtc) simulcast default settings.
-		// That would be 2.5 Mbps for 1280 x 720 (https://chromium.googlesource.com/external/webrtc/+/master/media/engine/simulcast.cc#83).
-		// That piece of code does not specify frame rate.
-		// But, assuming a frame rate of 30 fps, the equation above would yield a score of approximately 4.01
-		// under perfect conditions, i. e. no delay or jitter and expected frame rate matching actual frame rate.
-		//
-		// LK clients by default use 1.7 Mbps for 720p30 for vp8/h.264.
-		// That yields a score of approximately 3.8 using the above equation again under perfect conditions.
-		// The perceived quality is good at that bit rate (based on user perception),
-		// So, using a theshold like 3.5 MOS for declaring good quality should be fine.
-		//
-		base := clamp(0.56*math.Log(bPPPF)+5.36, 1, 5)
-
-		score.VideoScore = clamp(base-1.9*math.Log(float64(*videoConfig.ExpectedFrameRate)/frameRate)-delay*0.002, 1, 5)
-	} else {
-		score.VideoScore = 1
-	}
-	return score
+	//
+	// A bit of speculation on logarithmic regression equation from https://github.com/ggarber/rtcscore
+	// base := clamp(0.56*math.Log(bPPPF)+5.36, 1, 5)
+	// (the default BPPPFSlope and BPPPFIntercept)
+	//
+	// Assuming that derivation is based on Chrome (libwebrtc) simulcast default settings.
+	// That would be 2.5 Mbps for 1280 x 720 (https://chromium.googlesource.com/external/webrtc/+/master/media/engine/simulcast.cc#83).
+	// That piece of code does not specify frame rate.
+	// But, assuming a frame rate of 30 fps, the equation above would yield a score of approximately 4.01
+	// under perfect conditions, i. e. no delay or jitter and expected frame rate matching actual frame rate.
+	//
+	// LK clients by default use 1.7 Mbps for 720p30 for vp8/h.264.
+	// That yields a score of approximately 3.8 using the above equation again under perfect conditions.
+	// The perceived quality is good at that bit rate (based on user perception),
+	// So, using a theshold like 3.5 MOS for declaring good quality should be fine.
+	//
+	base := clamp(c.BPPPFSlope*math.Log(features.BPPPF)+c.BPPPFIntercept, 1, 5)
+
+	score := clamp(base-c.FrameRateFactor*math.Log(features.FrameRateRatio)-features.Delay*c.DelayFactor, 1, 5)
+
+	return Scores{VideoScore: clamp(1+(score-1)*features.QualityKept, 1, 5)}
 }
 
-func normalizeVideoStat(input Stat) Stat {
-	if input.RoundTripTime == nil {
-		input.RoundTripTime = int32Ptr(DefaultRoundTripTime)
+// VideoFeatures contains the inputs of the video regression derived from a Stat
+type VideoFeatures struct {
+	// BPPPF: bits per pixel per frame, scaled by the codec factor
+	BPPPF float64
+	// FrameRateRatio: expected frame rate over received frame rate
+	FrameRateRatio float64
+	// Delay: buffer delay plus half the round trip time (ms)
+	Delay float64
+	// ResidualLoss: packet loss left after NACK, FEC and RED recovery (percent)
+	ResidualLoss float64
+	// FreezeFactor: share of the quality above the minimum score kept given freezes
+	FreezeFactor float64
+	// QualityKept: share of the quality above the minimum score kept given residual loss and freezes
+	QualityKept float64
+}
+
+// ExtractVideoFeatures returns the inputs of the video regression for the stat,
+// false when the stat has no video config or no frames were received
+func ExtractVideoFeatures(input Stat, coefficients *Coefficients) (VideoFeatures, bool) {
+	if input.VideoConfig == nil {
+		return VideoFeatures{}, false
+	}
+	coefficients = coefficients.orDefault()
+	c := &coefficients.Video
+	stat := normalizeVideoStat(input, coefficients)
+	videoConfig := stat.VideoConfig
+	if *videoConfig.FrameRate == 0 {
+		return VideoFeatures{}, false
+	}
+
+	frameRate := float64(*videoConfig.FrameRate)
+	pixels := float64(*videoConfig.Width * *videoConfig.Height)
+	features := VideoFeatures{
+		BPPPF:          (c.codecFactor(videoConfig.Codec) * float64(stat.Bitrate)) / pixels / frameRate,
+		FrameRateRatio: float64(*videoConfig.ExpectedFrameRate) / frameRate,
+		Delay:          float64(*stat.BufferDelay + *stat.RoundTripTime/2),
 	}
 
-	if input.BufferDelay == nil {
-		input.BufferDelay = int32Ptr(DefaultBufferDelay)
+	// Packets lost after recovery show up as artifacts or freezes until the next key frame,
+	// so residual loss scales down the quality above the minimum score, similar to Ie-eff in the E-model.
+	features.ResidualLoss = residualVideoLoss(float64(stat.PacketLoss), float64(*stat.RoundTripTime), stat.Burst, videoConfig, c)
+	lossFactor := 0.0
+	if features.ResidualLoss > 0 {
+		lossFactor = clamp(features.ResidualLoss/(features.ResidualLoss/stat.Burst.burstR(float64(stat.PacketLoss))+c.Bpl), 0, 1)
 	}
 
+	// Stalls are perceived much more severely than a lower frame rate, even short and rare ones.
+	features.FreezeFactor = freezeFactor(stat, c)
+	features.QualityKept = (1 - lossFactor) * features.FreezeFactor
+
+	return features, true
+}
+
+// residualVideoLoss - packet loss (in percent) left after NACK, FEC and RED recovery
+func residualVideoLoss(packetLoss float64, roundTripTime float64, burst *BurstStat, videoConfig *VideoConfig, c *VideoCoefficients) float64 {
+	p := clamp(packetLoss/100, 0, 1)
+	burstR := burst.burstR(packetLoss)
+	if *videoConfig.Red {
+		// a packet is only lost if the redundant copy carried by the next packet is lost too,
+		// which is more likely when losses come in bursts
+		nextLost := p
+		if length := burst.meanBurstLength(packetLoss); length > 0 {
+			nextLost = clamp(1-1/length, 0, 1)
+		}
+		p = p * nextLost
+	}
+	if *videoConfig.Fec {
+		// FEC protects a limited number of consecutive packets,
+		// burstR is below 1 when losses are more isolated than random ones
+		p = p * (1 - clamp(c.FecRecovery/burstR, 0, 1)*(1-p))
+	}
+	if *videoConfig.Nack {
+		// retransmissions can be lost again and are useless when they arrive too late
+		inTime := clamp(1-roundTripTime/c.NackMaxRoundTripTime, 0, 1)
+		p = p * (1 - inTime*(1-p))
+	}
+	return p * 100
+}
+
+// freezeFactor - share of the quality kept given the freezes during the active (not paused) time of the interval
+func freezeFactor(stat Stat, c *VideoCoefficients) float64 {
+	videoConfig := stat.VideoConfig
+	active := float64(*stat.Interval - *videoConfig.TotalPausesDuration)
+	if active <= 0 {
+		return 1
+	}
+
+	frozen := clamp(float64(*videoConfig.TotalFreezesDuration)/active, 0, 1)
+	freezesPerMinute := float64(*videoConfig.FreezeCount) * 60000 / active
+	return math.Exp(-(c.FreezeDurationWeight*frozen + c.FreezeCountWeight*freezesPerMinute))
+}
+
+// normalizeVideoStat - fills the defaults on a copy, the caller's config is never modified
+func normalizeVideoStat(input Stat, coefficients *Coefficients) Stat {
+	input = normalizeNetworkStat(input, coefficients)
+
+	videoConfig := *input.VideoConfig
+	input.VideoConfig = &videoConfig
+
 	if input.VideoConfig.Width == nil {
 		input.VideoConfig.Width = int32Ptr(DefaultWidth)
 	}
@@ -99,7 +191,35 @@ func normalizeVideoStat(input Stat) Stat {
 	}
 
 	if input.VideoConfig.ExpectedFrameRate == nil {
-		input.VideoConfig.ExpectedFrameRate = input.VideoConfig.FrameRate
+		input.VideoConfig.ExpectedFrameRate = float32Ptr(*input.VideoConfig.FrameRate)
+	}
+
+	if input.VideoConfig.Nack == nil {
+		input.VideoConfig.Nack = boolPtr(true)
+	}
+
+	if input.VideoConfig.Fec == nil {
+		input.VideoConfig.Fec = boolPtr(false)
+	}
+
+	if input.VideoConfig.Red == nil {
+		input.VideoConfig.Red = boolPtr(false)
+	}
+
+	if input.Interval == nil {
+		input.Interval = int32Ptr(DefaultInterval)
+	}
+
+	if input.VideoConfig.FreezeCount == nil {
+		input.VideoConfig.FreezeCount = int32Ptr(0)
+	}
+
+	if input.VideoConfig.TotalFreezesDuration == nil {
+		input.VideoConfig.TotalFreezesDuration = int32Ptr(0)
+	}
+
+	if input.VideoConfig.TotalPausesDuration == nil {
+		input.VideoConfig.TotalPausesDuration = int32Ptr(0)
 	}
 
 	return input
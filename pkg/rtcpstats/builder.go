@@ -0,0 +1,309 @@
+package rtcpstats
+
+import (
+	"math"
+	"time"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+const (
+	// DefaultAudioClockRate, DefaultVideoClockRate: RTP clock rates used when the stream does not specify one
+	DefaultAudioClockRate = 48000
+	DefaultVideoClockRate = 90000
+)
+
+// StreamConfig describes a stream built by the Builder
+type StreamConfig struct {
+	// ClockRate: RTP clock rate of the stream (Hz) used to convert the jitter,
+	// defaults to DefaultAudioClockRate or DefaultVideoClockRate
+	ClockRate uint32
+	// AudioConfig, VideoConfig: configuration copied to the Stat of the stream, one of them must be set
+	AudioConfig *rtcmos.AudioConfig
+	VideoConfig *rtcmos.VideoConfig
+}
+
+// Builder derives the Stat of RTP streams from the RTCP packets about them, keyed by SSRC.
+//
+// Reception reports and XR report blocks about a stream come from its receivers, sender reports from the stream
+// sender. The round trip time is measured from the last sender report fields of reception reports and from DLRR
+// blocks, so the arrival time of the packets has to be accurate. Reception reports older than the latest one of the
+// stream, by extended highest sequence number, are out of order and ignored.
+type Builder struct {
+	streams map[uint32]*stream
+}
+
+// stream - RTCP received about a stream during the window
+type stream struct {
+	config StreamConfig
+	start  time.Time
+	// updated: RTCP was received about the stream during the window
+	updated bool
+
+	// firstReport, lastReport: reception reports at the start and the end of the window
+	firstReport *ReceptionReport
+	lastReport  *ReceptionReport
+	// firstSender, lastSender: sender reports at the start and the end of the window
+	firstSender *SenderReport
+	lastSender  *SenderReport
+
+	// roundTripTime: last measured round trip time (ms)
+	roundTripTime *int32
+	// rleReceived, rleLost, rleBursts: packets received, lost and runs of lost packets of loss RLE blocks
+	rleReceived int
+	rleLost     int
+	rleBursts   int
+
+	voipMetrics    *VoIPMetricsBlock
+	deJitterBuffer *DeJitterBufferBlock
+}
+
+// NewBuilder returns a Builder without streams
+func NewBuilder() *Builder {
+	return &Builder{streams: map[uint32]*stream{}}
+}
+
+// AddStream registers a stream, RTCP about unknown SSRCs is ignored
+func (b *Builder) AddStream(ssrc uint32, config StreamConfig) {
+	if config.ClockRate == 0 {
+		config.ClockRate = DefaultAudioClockRate
+		if config.VideoConfig != nil {
+			config.ClockRate = DefaultVideoClockRate
+		}
+	}
+	b.streams[ssrc] = &stream{config: config}
+}
+
+// RemoveStream stops building the stat of a stream
+func (b *Builder) RemoveStream(ssrc uint32) {
+	delete(b.streams, ssrc)
+}
+
+// Add ingests the packets of a compound RTCP packet received at the arrival time
+func (b *Builder) Add(packets []Packet, arrival time.Time) {
+	for _, packet := range packets {
+		switch p := packet.(type) {
+		case *SenderReport:
+			if s := b.stream(p.SSRC, arrival); s != nil {
+				if s.firstSender == nil {
+					s.firstSender = p
+				}
+				s.lastSender = p
+			}
+			b.addReports(p.Reports, arrival)
+
+		case *ReceiverReport:
+			b.addReports(p.Reports, arrival)
+
+		case *ExtendedReport:
+			for _, block := range p.Blocks {
+				b.addBlock(p.SSRC, block, arrival)
+			}
+		}
+	}
+}
+
+func (b *Builder) addReports(reports []ReceptionReport, arrival time.Time) {
+	for i := range reports {
+		report := &reports[i]
+		// the extended highest sequence number received only moves forward
+		if s, ok := b.streams[report.SSRC]; ok && s.lastReport != nil &&
+			int32(report.LastSequenceNumber-s.lastReport.LastSequenceNumber) < 0 {
+			continue
+		}
+		s := b.stream(report.SSRC, arrival)
+		if s == nil {
+			continue
+		}
+		if s.firstReport == nil {
+			s.firstReport = report
+		}
+		s.lastReport = report
+		if report.LastSenderReport != 0 {
+			s.setRoundTripTime(roundTripTime(arrival, report.LastSenderReport, report.Delay))
+		}
+	}
+}
+
+func (b *Builder) addBlock(sender uint32, block Block, arrival time.Time) {
+	switch blk := block.(type) {
+	case *LossRLEBlock:
+		if s := b.stream(blk.SSRC, arrival); s != nil {
+			// a burst is a run of lost packets, which may span several chunks
+			lost := false
+			blk.Runs(func(received bool, length int) {
+				if length == 0 {
+					return
+				}
+				if received {
+					s.rleReceived += length
+				} else {
+					s.rleLost += length
+					if !lost {
+						s.rleBursts++
+					}
+				}
+				lost = !received
+			})
+		}
+
+	case *DLRRBlock:
+		// replies of the sender of the stream to the receiver reference time
+		if s := b.stream(sender, arrival); s != nil {
+			for _, report := range blk.Reports {
+				if report.LastReceiverReport != 0 {
+					s.setRoundTripTime(roundTripTime(arrival, report.LastReceiverReport, report.Delay))
+				}
+			}
+		}
+
+	case *VoIPMetricsBlock:
+		if s := b.stream(blk.SSRC, arrival); s != nil {
+			s.voipMetrics = blk
+		}
+
+	case *DeJitterBufferBlock:
+		if s := b.stream(blk.SSRC, arrival); s != nil {
+			s.deJitterBuffer = blk
+		}
+	}
+}
+
+// stream - registered stream of the SSRC marked as updated, nil when unknown
+func (b *Builder) stream(ssrc uint32, arrival time.Time) *stream {
+	s, ok := b.streams[ssrc]
+	if !ok {
+		return nil
+	}
+	if s.start.IsZero() {
+		s.start = arrival
+	}
+	s.updated = true
+	return s
+}
+
+func (s *stream) setRoundTripTime(rtt time.Duration, ok bool) {
+	if ok {
+		s.roundTripTime = int32Ptr(int32(rtt.Milliseconds()))
+	}
+}
+
+// Stats returns the Stat of the streams RTCP was received about since the previous call, and starts a new window
+func (b *Builder) Stats(now time.Time) map[uint32]rtcmos.Stat {
+	stats := map[uint32]rtcmos.Stat{}
+	for ssrc, s := range b.streams {
+		if !s.updated {
+			continue
+		}
+		stats[ssrc] = s.stat(now)
+
+		// the last reports are the start of the next window
+		*s = stream{
+			config:        s.config,
+			start:         now,
+			firstReport:   s.lastReport,
+			lastReport:    s.lastReport,
+			firstSender:   s.lastSender,
+			lastSender:    s.lastSender,
+			roundTripTime: s.roundTripTime,
+		}
+	}
+	return stats
+}
+
+func (s *stream) stat(now time.Time) rtcmos.Stat {
+	stat := rtcmos.Stat{
+		RoundTripTime: s.roundTripTime,
+		Interval:      int32Ptr(int32(math.Max(1, float64(now.Sub(s.start).Milliseconds())))),
+	}
+
+	// packet loss, from the most to the least precise source
+	switch {
+	case s.rleReceived+s.rleLost > 0:
+		stat.PacketLoss = float32(s.rleLost) * 100 / float32(s.rleReceived+s.rleLost)
+		if s.rleBursts > 0 {
+			stat.Burst = &rtcmos.BurstStat{MeanBurstLength: float32(s.rleLost) / float32(s.rleBursts)}
+		}
+	case s.lastReport != nil && s.lastReport != s.firstReport &&
+		s.lastReport.LastSequenceNumber != s.firstReport.LastSequenceNumber:
+		expected := float64(s.lastReport.LastSequenceNumber - s.firstReport.LastSequenceNumber)
+		lost := float64(s.lastReport.TotalLost - s.firstReport.TotalLost)
+		stat.PacketLoss = float32(math.Max(0, math.Min(100, lost*100/expected)))
+	case s.lastReport != nil:
+		stat.PacketLoss = float32(s.lastReport.FractionLost) * 100 / 256
+	case s.voipMetrics != nil:
+		stat.PacketLoss = float32(s.voipMetrics.LossRate) * 100 / 256
+	}
+
+	if s.lastReport != nil {
+		stat.Jitter = float32(float64(s.lastReport.Jitter) * 1000 / float64(s.config.ClockRate))
+	}
+
+	if s.lastSender != nil && s.lastSender != s.firstSender {
+		duration := ntpSeconds(s.lastSender.NTPTime) - ntpSeconds(s.firstSender.NTPTime)
+		if duration > 0 {
+			// octet count wraps around at 32 bits
+			octets := s.lastSender.OctetCount - s.firstSender.OctetCount
+			stat.Bitrate = float32(float64(octets) * 8 / duration)
+		}
+	}
+
+	if v := s.voipMetrics; v != nil {
+		stat.JitterBufferDiscard = float32(v.DiscardRate) * 100 / 256
+		if v.BurstDensity > 0 || v.GapDensity > 0 {
+			if stat.Burst == nil {
+				stat.Burst = &rtcmos.BurstStat{}
+			}
+			stat.Burst.BurstDensity = float32(v.BurstDensity) * 100 / 256
+			stat.Burst.GapDensity = float32(v.GapDensity) * 100 / 256
+		}
+		if stat.RoundTripTime == nil && v.RoundTripDelay > 0 {
+			stat.RoundTripTime = int32Ptr(int32(v.RoundTripDelay))
+		}
+		if v.JBNominal > 0 {
+			stat.BufferDelay = int32Ptr(int32(v.JBNominal))
+		}
+	}
+	if d := s.deJitterBuffer; d != nil && d.Nominal < DeJitterBufferOverRange {
+		stat.BufferDelay = int32Ptr(int32(d.Nominal))
+	}
+
+	if s.config.AudioConfig != nil {
+		audioConfig := *s.config.AudioConfig
+		stat.AudioConfig = &audioConfig
+	}
+	if s.config.VideoConfig != nil {
+		videoConfig := *s.config.VideoConfig
+		stat.VideoConfig = &videoConfig
+	}
+	return stat
+}
+
+// ntpEpochOffset - seconds between the NTP (1900) and unix (1970) epochs
+const ntpEpochOffset = 2208988800
+
+// NTPTime returns the 64 bits NTP timestamp of the time
+func NTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / 1e9
+	return seconds<<32 | fraction
+}
+
+func ntpSeconds(ntp uint64) float64 {
+	return float64(ntp>>32) + float64(uint32(ntp))/(1<<32)
+}
+
+// roundTripTime - round trip time from the middle 32 bits NTP time a report was sent at and the delay
+// until it was answered, false when the result is not plausible (clock issues, old report)
+func roundTripTime(arrival time.Time, sent uint32, delay uint32) (time.Duration, bool) {
+	compact := uint32(NTPTime(arrival) >> 16)
+	rtt := int32(compact - sent - delay)
+	if rtt < 0 {
+		return 0, false
+	}
+	return time.Duration(int64(rtt) * int64(time.Second) / 65536), true
+}
+
+func int32Ptr(x int32) *int32 {
+	return &x
+}
@@ -0,0 +1,170 @@
+package rtcpstats
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// RTCP packet types (RFC 3550, RFC 3611)
+const (
+	TypeSenderReport   = 200
+	TypeReceiverReport = 201
+	TypeExtendedReport = 207
+)
+
+const (
+	headerLength          = 4
+	receptionReportLength = 24
+	senderInfoLength      = 20
+)
+
+// ErrInvalidPacket is returned when the data is not a valid compound RTCP packet
+var ErrInvalidPacket = errors.New("invalid RTCP packet")
+
+// Packet is a parsed RTCP packet: *SenderReport, *ReceiverReport or *ExtendedReport
+type Packet interface {
+	packetType() uint8
+}
+
+// ReceptionReport is a report block of a sender or receiver report about the reception of a source
+type ReceptionReport struct {
+	// SSRC: source the report is about
+	SSRC uint32
+	// FractionLost: fraction of the packets lost since the previous report, in 1/256
+	FractionLost uint8
+	// TotalLost: cumulative number of packets lost, 24 bits signed
+	TotalLost int32
+	// LastSequenceNumber: extended highest sequence number received
+	LastSequenceNumber uint32
+	// Jitter: interarrival jitter in RTP timestamp units
+	Jitter uint32
+	// LastSenderReport: middle 32 bits of the NTP timestamp of the last sender report received, 0 if none
+	LastSenderReport uint32
+	// Delay: delay since the last sender report was received, in 1/65536 s
+	Delay uint32
+}
+
+// SenderReport is an RTCP SR packet
+type SenderReport struct {
+	SSRC uint32
+	// NTPTime: wallclock time of the report, 64 bits NTP format
+	NTPTime     uint64
+	RTPTime     uint32
+	PacketCount uint32
+	OctetCount  uint32
+	Reports     []ReceptionReport
+}
+
+func (*SenderReport) packetType() uint8 {
+	return TypeSenderReport
+}
+
+// ReceiverReport is an RTCP RR packet
+type ReceiverReport struct {
+	SSRC    uint32
+	Reports []ReceptionReport
+}
+
+func (*ReceiverReport) packetType() uint8 {
+	return TypeReceiverReport
+}
+
+// Unmarshal parses a compound RTCP packet, packets other than SR, RR and XR are skipped
+func Unmarshal(data []byte) ([]Packet, error) {
+	var packets []Packet
+	for len(data) > 0 {
+		if len(data) < headerLength {
+			return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidPacket, len(data))
+		}
+		if version := data[0] >> 6; version != 2 {
+			return nil, fmt.Errorf("%w: version %d", ErrInvalidPacket, version)
+		}
+		length := (int(binary.BigEndian.Uint16(data[2:])) + 1) * 4
+		if length > len(data) {
+			return nil, fmt.Errorf("%w: length %d exceeds %d bytes", ErrInvalidPacket, length, len(data))
+		}
+
+		body := data[headerLength:length]
+		if padding := data[0]&0x20 != 0; padding {
+			count := int(data[length-1])
+			if count == 0 || count > len(body) {
+				return nil, fmt.Errorf("%w: padding of %d bytes", ErrInvalidPacket, count)
+			}
+			body = body[:len(body)-count]
+		}
+		count := int(data[0] & 0x1f)
+
+		var packet Packet
+		var err error
+		switch data[1] {
+		case TypeSenderReport:
+			packet, err = unmarshalSenderReport(body, count)
+		case TypeReceiverReport:
+			packet, err = unmarshalReceiverReport(body, count)
+		case TypeExtendedReport:
+			packet, err = unmarshalExtendedReport(body)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if packet != nil {
+			packets = append(packets, packet)
+		}
+		data = data[length:]
+	}
+	return packets, nil
+}
+
+// marshalPacket - prepends the RTCP header to the body, a multiple of 4 bytes
+func marshalPacket(packetType uint8, count int, body []byte) []byte {
+	data := make([]byte, headerLength, headerLength+len(body))
+	data[0] = 2<<6 | uint8(count)&0x1f
+	data[1] = packetType
+	binary.BigEndian.PutUint16(data[2:], uint16(len(body)/4))
+	return append(data, body...)
+}
+
+func unmarshalSenderReport(body []byte, count int) (*SenderReport, error) {
+	if len(body) < 4+senderInfoLength+count*receptionReportLength {
+		return nil, fmt.Errorf("%w: sender report too short", ErrInvalidPacket)
+	}
+	report := &SenderReport{
+		SSRC:        binary.BigEndian.Uint32(body),
+		NTPTime:     binary.BigEndian.Uint64(body[4:]),
+		RTPTime:     binary.BigEndian.Uint32(body[12:]),
+		PacketCount: binary.BigEndian.Uint32(body[16:]),
+		OctetCount:  binary.BigEndian.Uint32(body[20:]),
+	}
+	report.Reports = unmarshalReceptionReports(body[4+senderInfoLength:], count)
+	return report, nil
+}
+
+func unmarshalReceiverReport(body []byte, count int) (*ReceiverReport, error) {
+	if len(body) < 4+count*receptionReportLength {
+		return nil, fmt.Errorf("%w: receiver report too short", ErrInvalidPacket)
+	}
+	return &ReceiverReport{
+		SSRC:    binary.BigEndian.Uint32(body),
+		Reports: unmarshalReceptionReports(body[4:], count),
+	}, nil
+}
+
+func unmarshalReceptionReports(data []byte, count int) []ReceptionReport {
+	reports := make([]ReceptionReport, count)
+	for i := range reports {
+		block := data[i*receptionReportLength:]
+		// sign extension of the 24 bits cumulative number of packets lost
+		totalLost := int32(binary.BigEndian.Uint32(block[4:])<<8) >> 8
+		reports[i] = ReceptionReport{
+			SSRC:               binary.BigEndian.Uint32(block),
+			FractionLost:       block[4],
+			TotalLost:          totalLost,
+			LastSequenceNumber: binary.BigEndian.Uint32(block[8:]),
+			Jitter:             binary.BigEndian.Uint32(block[12:]),
+			LastSenderReport:   binary.BigEndian.Uint32(block[16:]),
+			Delay:              binary.BigEndian.Uint32(block[20:]),
+		}
+	}
+	return reports
+}
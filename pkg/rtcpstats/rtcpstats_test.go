@@ -0,0 +1,331 @@
+package rtcpstats
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+// packet - RTCP packet with the header, count and body
+func packet(packetType uint8, count int, body []byte) []byte {
+	data := []byte{0x80 | uint8(count), packetType, 0, 0}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(body)/4))
+	return append(data, body...)
+}
+
+func words(values ...uint32) []byte {
+	data := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint32(data[4*i:], value)
+	}
+	return data
+}
+
+func chunks(values ...uint16) []byte {
+	data := make([]byte, 2*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint16(data[2*i:], value)
+	}
+	return data
+}
+
+func receptionReport(r ReceptionReport) []byte {
+	return words(r.SSRC, uint32(r.FractionLost)<<24|uint32(r.TotalLost)&0xffffff, r.LastSequenceNumber, r.Jitter, r.LastSenderReport, r.Delay)
+}
+
+func receiverReport(ssrc uint32, reports ...ReceptionReport) []byte {
+	body := words(ssrc)
+	for _, r := range reports {
+		body = append(body, receptionReport(r)...)
+	}
+	return packet(TypeReceiverReport, len(reports), body)
+}
+
+func senderReport(ssrc uint32, ntp time.Time, octets uint32) []byte {
+	ntpTime := NTPTime(ntp)
+	return packet(TypeSenderReport, 0, words(ssrc, uint32(ntpTime>>32), uint32(ntpTime), 0, 0, octets))
+}
+
+// block - XR block with the header and body
+func block(blockType uint8, typeSpecific uint8, body []byte) []byte {
+	data := []byte{blockType, typeSpecific, 0, 0}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(body)/4))
+	return append(data, body...)
+}
+
+func extendedReport(ssrc uint32, blocks ...[]byte) []byte {
+	body := words(ssrc)
+	for _, b := range blocks {
+		body = append(body, b...)
+	}
+	return packet(TypeExtendedReport, 0, body)
+}
+
+func voipMetricsBlock(ssrc uint32) []byte {
+	return block(BlockTypeVoIPMetrics, 0, words(ssrc,
+		// loss rate 5%, discard rate 2.5%, burst density 50%, gap density 1%
+		13<<24|6<<16|128<<8|3,
+		// burst duration, gap duration
+		40<<16|2000,
+		// round trip delay, end system delay
+		120<<16|60,
+		// signal, noise, RERL, Gmin
+		0<<24|0<<16|127<<8|16,
+		// R factor, ext R factor, MOS-LQ, MOS-CQ
+		127<<24|127<<16|127<<8|127,
+		// RX config, reserved, JB nominal
+		0<<24|0<<16|60,
+		// JB maximum, JB abs max
+		120<<16|200,
+	))
+}
+
+func TestUnmarshal(t *testing.T) {
+	{
+		// compound packet with a sender report, a receiver report with a negative total lost and an extended report
+		now := time.Unix(1700000000, 500000000)
+		data := append(senderReport(1, now, 1000), receiverReport(2, ReceptionReport{SSRC: 1, FractionLost: 64, TotalLost: -3, LastSequenceNumber: 70000, Jitter: 480})...)
+		data = append(data, extendedReport(2,
+			block(BlockTypeLossRLE, 0, append(words(1, 100<<16|140), chunks(0x4000|20, 0x8000|0x7ff0, 0x0005, 0)...)),
+			block(BlockTypeReceiverReferenceTime, 0, words(1, 2)),
+			block(BlockTypeDLRR, 0, words(3, 4, 5)),
+			voipMetricsBlock(1),
+			block(BlockTypeDeJitterBuffer, 0x60, words(1, 80<<16|150, 160<<16|20)),
+			block(99, 0, words(0)),
+		)...)
+		packets, err := Unmarshal(data)
+		require.NoError(t, err)
+		require.Len(t, packets, 3)
+
+		sr := packets[0].(*SenderReport)
+		require.Equal(t, uint32(1), sr.SSRC)
+		require.Equal(t, uint32(1000), sr.OctetCount)
+		require.InDelta(t, 1700000000.5+ntpEpochOffset, ntpSeconds(sr.NTPTime), 1e-6)
+
+		rr := packets[1].(*ReceiverReport)
+		require.Equal(t, []ReceptionReport{{SSRC: 1, FractionLost: 64, TotalLost: -3, LastSequenceNumber: 70000, Jitter: 480}}, rr.Reports)
+
+		xr := packets[2].(*ExtendedReport)
+		require.Len(t, xr.Blocks, 5)
+		rle := xr.Blocks[0].(*LossRLEBlock)
+		require.Equal(t, uint16(100), rle.BeginSequence)
+		var runs []int
+		rle.Runs(func(received bool, length int) {
+			if !received {
+				length = -length
+			}
+			runs = append(runs, length)
+		})
+		// 20 received, then a bit vector of 11 received and 4 lost, and a run of 5 lost
+		require.Equal(t, []int{20, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -5}, runs)
+		require.Equal(t, uint64(1<<32|2), xr.Blocks[1].(*ReceiverReferenceTimeBlock).NTPTime)
+		require.Equal(t, []DLRRReport{{SSRC: 3, LastReceiverReport: 4, Delay: 5}}, xr.Blocks[2].(*DLRRBlock).Reports)
+		voip := xr.Blocks[3].(*VoIPMetricsBlock)
+		require.Equal(t, uint8(13), voip.LossRate)
+		require.Equal(t, uint16(120), voip.RoundTripDelay)
+		require.Equal(t, uint16(200), voip.JBAbsMax)
+		require.Equal(t, &DeJitterBufferBlock{Interval: 1, Adaptive: true, SSRC: 1, Nominal: 80, Maximum: 150, HighWaterMark: 160, LowWaterMark: 20}, xr.Blocks[4])
+	}
+	{
+		// other packet types are skipped, padding is removed
+		bye := packet(203, 1, words(1))
+		padded := packet(TypeReceiverReport, 0, append(words(7), 0, 0, 0, 4))
+		padded[0] |= 0x20
+		packets, err := Unmarshal(append(bye, padded...))
+		require.NoError(t, err)
+		require.Equal(t, []Packet{&ReceiverReport{SSRC: 7, Reports: []ReceptionReport{}}}, packets)
+	}
+	{
+		// invalid packets
+		_, err := Unmarshal([]byte{0x80, 201, 0})
+		require.ErrorIs(t, err, ErrInvalidPacket)
+		_, err = Unmarshal([]byte{0x40, 201, 0, 0})
+		require.ErrorIs(t, err, ErrInvalidPacket)
+		_, err = Unmarshal(packet(TypeReceiverReport, 1, words(1)))
+		require.ErrorIs(t, err, ErrInvalidPacket)
+		truncated := receiverReport(1)
+		truncated[3] = 5
+		_, err = Unmarshal(truncated)
+		require.ErrorIs(t, err, ErrInvalidPacket)
+		_, err = Unmarshal(extendedReport(1, block(BlockTypeVoIPMetrics, 0, words(1))))
+		require.ErrorIs(t, err, ErrInvalidPacket)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	builder := NewBuilder()
+	builder.AddStream(1, StreamConfig{AudioConfig: &rtcmos.AudioConfig{}})
+	builder.AddStream(2, StreamConfig{VideoConfig: &rtcmos.VideoConfig{Codec: "vp8"}})
+
+	add := func(data []byte, arrival time.Time) {
+		packets, err := Unmarshal(data)
+		require.NoError(t, err)
+		builder.Add(packets, arrival)
+	}
+
+	{
+		// sender reports of the video stream and receiver reports answering them 100 ms later
+		sent := start.Add(200 * time.Millisecond)
+		add(senderReport(2, start, 0), start)
+		add(senderReport(2, sent, 12500), sent)
+		lsr := uint32(NTPTime(sent) >> 16)
+		add(receiverReport(9,
+			ReceptionReport{SSRC: 2, TotalLost: 10, LastSequenceNumber: 1000, Jitter: 900},
+		), start.Add(100*time.Millisecond))
+		add(receiverReport(9,
+			ReceptionReport{SSRC: 2, TotalLost: 30, LastSequenceNumber: 1200, Jitter: 1800, LastSenderReport: lsr, Delay: 65536 / 50},
+		), sent.Add(100*time.Millisecond))
+		// about an unknown stream
+		add(receiverReport(9, ReceptionReport{SSRC: 5, FractionLost: 255}), sent)
+
+		stats := builder.Stats(start.Add(time.Second))
+		require.Len(t, stats, 1)
+		video := stats[2]
+		// 20 lost out of 200 expected
+		require.InDelta(t, 10, video.PacketLoss, 1e-4)
+		require.InDelta(t, 20, video.Jitter, 1e-4)
+		require.InDelta(t, 500000, video.Bitrate, 1)
+		require.InDelta(t, 80, *video.RoundTripTime, 1)
+		require.Equal(t, int32(1000), *video.Interval)
+		require.Equal(t, "vp8", video.VideoConfig.Codec)
+		require.NoError(t, video.Validate())
+
+		// the next window starts from the last reports
+		add(receiverReport(9, ReceptionReport{SSRC: 2, TotalLost: 30, LastSequenceNumber: 1400, Jitter: 900}), start.Add(1500*time.Millisecond))
+		video = builder.Stats(start.Add(2 * time.Second))[2]
+		require.Equal(t, float32(0), video.PacketLoss)
+		require.Equal(t, int32(1000), *video.Interval)
+		require.Empty(t, builder.Stats(start.Add(3*time.Second)))
+	}
+	{
+		// a delayed report older than the latest one is ignored
+		builder.AddStream(3, StreamConfig{AudioConfig: &rtcmos.AudioConfig{}})
+		arrival := start.Add(20 * time.Second)
+		add(receiverReport(9, ReceptionReport{SSRC: 3, TotalLost: 10, LastSequenceNumber: 1000}), arrival)
+		add(receiverReport(9, ReceptionReport{SSRC: 3, TotalLost: 30, LastSequenceNumber: 1200}), arrival.Add(500*time.Millisecond))
+		add(receiverReport(9, ReceptionReport{SSRC: 3, TotalLost: 25, LastSequenceNumber: 1100}), arrival.Add(900*time.Millisecond))
+		// 20 lost out of 200 expected
+		require.InDelta(t, 10, builder.Stats(arrival.Add(time.Second))[3].PacketLoss, 1e-4)
+
+		// a window with only out of order reports has no stat
+		add(receiverReport(9, ReceptionReport{SSRC: 3, TotalLost: 20, LastSequenceNumber: 1100}), arrival.Add(1500*time.Millisecond))
+		require.Empty(t, builder.Stats(arrival.Add(2*time.Second)))
+		builder.RemoveStream(3)
+	}
+	{
+		// extended reports about the audio stream
+		arrival := start.Add(3 * time.Second)
+		add(extendedReport(9,
+			block(BlockTypeLossRLE, 0, append(words(1, 0<<16|100), chunks(0x4000|80, 0x0004, 0x4000|12, 0x0004)...)),
+			voipMetricsBlock(1),
+			block(BlockTypeDeJitterBuffer, 0x60, words(1, 80<<16|150, 160<<16|20)),
+		), arrival)
+		audio := builder.Stats(arrival.Add(5 * time.Second))[1]
+		require.InDelta(t, 8, audio.PacketLoss, 1e-4)
+		require.Equal(t, &rtcmos.BurstStat{MeanBurstLength: 4, BurstDensity: 50, GapDensity: float32(3) * 100 / 256}, audio.Burst)
+		require.InDelta(t, 600.0/256, audio.JitterBufferDiscard, 1e-4)
+		require.Equal(t, int32(120), *audio.RoundTripTime)
+		require.Equal(t, int32(80), *audio.BufferDelay)
+		require.Equal(t, int32(5000), *audio.Interval)
+		require.NotNil(t, audio.AudioConfig)
+		require.NoError(t, audio.Validate())
+	}
+	{
+		// round trip time measured by the receiver with DLRR replies of the sender
+		arrival := start.Add(10 * time.Second)
+		lrr := uint32(NTPTime(arrival.Add(-300*time.Millisecond)) >> 16)
+		add(extendedReport(1, block(BlockTypeDLRR, 0, words(9, lrr, 65536/10))), arrival)
+		require.InDelta(t, 200, *builder.Stats(arrival)[1].RoundTripTime, 1)
+
+		builder.RemoveStream(1)
+		add(extendedReport(1, block(BlockTypeDLRR, 0, words(9, lrr, 65536/10))), arrival)
+		require.Empty(t, builder.Stats(arrival))
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	report := &ExtendedReport{SSRC: 9, Blocks: []Block{
+		&LossRLEBlock{SSRC: 1, Thinning: 2, BeginSequence: 10, EndSequence: 50, Chunks: []uint16{0x4000 | 30, 0x000a}},
+		&ReceiverReferenceTimeBlock{NTPTime: 1<<40 | 7},
+		&DLRRBlock{Reports: []DLRRReport{{SSRC: 1, LastReceiverReport: 2, Delay: 3}, {SSRC: 4, LastReceiverReport: 5, Delay: 6}}},
+		&VoIPMetricsBlock{SSRC: 1, LossRate: 3, SignalLevel: -20, NoiseLevel: VoIPMetricsUnavailable, RFactor: 80, MOSCQ: 41, JBNominal: 60, JBAbsMax: 300},
+		&DeJitterBufferBlock{Interval: 1, Adaptive: true, SSRC: 1, Nominal: 40, Maximum: 100, HighWaterMark: 90, LowWaterMark: 20},
+	}}
+	data := report.Marshal()
+	require.Equal(t, TypeExtendedReport, int(data[1]))
+
+	packets, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, []Packet{report}, packets)
+
+	// odd number of chunks, padded with a null chunk
+	rle := (&LossRLEBlock{SSRC: 1, Chunks: []uint16{0x4000 | 30}}).Marshal()
+	require.Equal(t, []byte{0x40, 30, 0, 0}, rle[12:])
+}
+
+func TestVoIPMetricsBlock(t *testing.T) {
+	stat := rtcmos.Stat{
+		PacketLoss:          5,
+		JitterBufferDiscard: 1,
+		RoundTripTime:       int32Ptr(120),
+		BufferDelay:         int32Ptr(60),
+		Interval:            int32Ptr(5000),
+		AudioConfig:         &rtcmos.AudioConfig{Bandwidth: rtcmos.AudioBandwidthWideband},
+	}
+	scores := rtcmos.AudioScore(stat)
+	{
+		// random loss
+		block := NewVoIPMetricsBlock(1, stat, scores)
+		require.Equal(t, uint8(13), block.LossRate)
+		require.Equal(t, uint8(3), block.DiscardRate)
+		require.Equal(t, uint8(0), block.BurstDensity)
+		require.Equal(t, uint8(13), block.GapDensity)
+		require.Equal(t, uint16(5000), block.GapDuration)
+		require.Equal(t, uint16(120), block.RoundTripDelay)
+		require.Equal(t, uint16(60), block.JBNominal)
+		require.Equal(t, uint8(DefaultGmin), block.Gmin)
+		// R on the narrowband scale
+		require.Equal(t, uint8(math.Round(scores.RFactor/1.29)), block.RFactor)
+		require.Equal(t, uint8(math.Round(scores.AudioScore*10)), block.MOSCQ)
+		require.Equal(t, uint8(math.Round(scores.AudioListeningScore*10)), block.MOSLQ)
+		require.Greater(t, block.MOSLQ, block.MOSCQ)
+		require.Equal(t, int8(VoIPMetricsUnavailable), block.SignalLevel)
+	}
+	{
+		// bursts of 5 packets: 12.5 lost packets in 2.5 bursts of 100 ms
+		stat := stat
+		stat.Burst = &rtcmos.BurstStat{MeanBurstLength: 5}
+		block := NewVoIPMetricsBlock(1, stat, rtcmos.AudioScore(stat))
+		require.Equal(t, uint8(255), block.BurstDensity)
+		require.Equal(t, uint8(0), block.GapDensity)
+		require.Equal(t, uint16(100), block.BurstDuration)
+		require.Equal(t, uint16(1900), block.GapDuration)
+	}
+	{
+		// not scored
+		block := NewVoIPMetricsBlock(1, stat, rtcmos.Scores{})
+		require.Equal(t, uint8(VoIPMetricsUnavailable), block.RFactor)
+		require.Equal(t, uint8(VoIPMetricsUnavailable), block.MOSCQ)
+		require.Equal(t, uint8(VoIPMetricsUnavailable), block.MOSLQ)
+	}
+	{
+		// read back by the builder
+		builder := NewBuilder()
+		builder.AddStream(1, StreamConfig{AudioConfig: &rtcmos.AudioConfig{}})
+		packets, err := Unmarshal((&ExtendedReport{SSRC: 9, Blocks: []Block{NewVoIPMetricsBlock(1, stat, scores)}}).Marshal())
+		require.NoError(t, err)
+		now := time.Now()
+		builder.Add(packets, now)
+		read := builder.Stats(now)[1]
+		require.InDelta(t, 5, read.PacketLoss, 0.2)
+		require.InDelta(t, 1, read.JitterBufferDiscard, 0.2)
+		require.Equal(t, int32(120), *read.RoundTripTime)
+		require.Equal(t, int32(60), *read.BufferDelay)
+	}
+}
@@ -0,0 +1,98 @@
+package rtcpstats
+
+import (
+	"math"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+const (
+	// VoIPMetricsUnavailable: value of the levels, R factors and MOS fields of a VoIP metrics block when not available
+	VoIPMetricsUnavailable = 127
+	// DefaultGmin: gap threshold recommended by RFC 3611, bursts are losses separated by less received packets
+	DefaultGmin = 16
+	// DefaultPacketTime: duration of audio packets (ms) used to convert burst lengths to durations
+	DefaultPacketTime = 20
+)
+
+// NewVoIPMetricsBlock returns the VoIP metrics block of the audio stream with the SSRC, built from its stat
+// and its scores (see rtcmos.AudioScore).
+//
+// MOS-CQ is the audio score, which includes the delay impairment, and MOS-LQ the audio listening score.
+// The end system delay is the jitter buffer delay. Burst durations are estimated from the mean burst length of the
+// stat with packets of DefaultPacketTime. The levels, RERL, RX config and jitter buffer maximums are not known and
+// may be set by the caller.
+func NewVoIPMetricsBlock(ssrc uint32, stat rtcmos.Stat, scores rtcmos.Scores) *VoIPMetricsBlock {
+	block := &VoIPMetricsBlock{
+		SSRC:        ssrc,
+		LossRate:    fraction(stat.PacketLoss),
+		DiscardRate: fraction(stat.JitterBufferDiscard),
+		SignalLevel: VoIPMetricsUnavailable,
+		NoiseLevel:  VoIPMetricsUnavailable,
+		RERL:        VoIPMetricsUnavailable,
+		Gmin:        DefaultGmin,
+		RFactor:     VoIPMetricsUnavailable,
+		ExtRFactor:  VoIPMetricsUnavailable,
+		MOSLQ:       VoIPMetricsUnavailable,
+		MOSCQ:       VoIPMetricsUnavailable,
+	}
+	if stat.RoundTripTime != nil {
+		block.RoundTripDelay = milliseconds(float64(*stat.RoundTripTime))
+	}
+	if stat.BufferDelay != nil {
+		block.EndSystemDelay = milliseconds(float64(*stat.BufferDelay))
+		block.JBNominal = milliseconds(float64(*stat.BufferDelay))
+	}
+
+	interval := float64(rtcmos.DefaultInterval)
+	if stat.Interval != nil {
+		interval = float64(*stat.Interval)
+	}
+	if burst := stat.Burst; burst != nil && (burst.MeanBurstLength > 0 || burst.BurstDensity > 0 || burst.GapDensity > 0) {
+		block.BurstDensity = fraction(burst.BurstDensity)
+		block.GapDensity = fraction(burst.GapDensity)
+		if length := float64(burst.MeanBurstLength); length > 0 {
+			if burst.BurstDensity == 0 && burst.GapDensity == 0 {
+				// only the lengths are known, the losses are consecutive
+				block.BurstDensity = 255
+			}
+			lost := interval / DefaultPacketTime * float64(stat.PacketLoss) / 100
+			bursts := math.Max(1, lost/length)
+			block.BurstDuration = milliseconds(length * DefaultPacketTime)
+			block.GapDuration = milliseconds((interval - bursts*length*DefaultPacketTime) / bursts)
+		}
+	} else {
+		// random loss, the whole interval is a gap
+		block.GapDensity = block.LossRate
+		block.GapDuration = milliseconds(interval)
+	}
+
+	if scores.AudioScore > 0 {
+		scale := 1.0
+		if stat.AudioConfig != nil && stat.AudioConfig.Bandwidth != "" {
+			scale = stat.AudioConfig.Bandwidth.Scale()
+		}
+		// the R factor field is on the narrowband scale
+		block.RFactor = uint8(math.Round(math.Max(0, math.Min(scores.RFactor/scale, 100))))
+		block.MOSCQ = mos(scores.AudioScore)
+	}
+	if scores.AudioListeningScore > 0 {
+		block.MOSLQ = mos(scores.AudioListeningScore)
+	}
+	return block
+}
+
+// fraction - percentage in 1/256, saturating at 255
+func fraction(percent float32) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(float64(percent)*256/100, 255))))
+}
+
+// milliseconds - duration field, saturating at 65535
+func milliseconds(duration float64) uint16 {
+	return uint16(math.Round(math.Max(0, math.Min(duration, 65535))))
+}
+
+// mos - MOS field in tenths, from 10 to 50
+func mos(score float64) uint8 {
+	return uint8(math.Round(math.Max(10, math.Min(score*10, 50))))
+}
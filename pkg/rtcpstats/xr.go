@@ -0,0 +1,373 @@
+package rtcpstats
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// RTCP XR block types (RFC 3611, RFC 7005)
+const (
+	BlockTypeLossRLE               = 1
+	BlockTypeReceiverReferenceTime = 4
+	BlockTypeDLRR                  = 5
+	BlockTypeVoIPMetrics           = 7
+	BlockTypeDeJitterBuffer        = 23
+)
+
+// ExtendedReport is an RTCP XR packet, blocks of other types are skipped
+type ExtendedReport struct {
+	SSRC uint32
+	// Blocks: *LossRLEBlock, *ReceiverReferenceTimeBlock, *DLRRBlock, *VoIPMetricsBlock or *DeJitterBufferBlock
+	Blocks []Block
+}
+
+func (*ExtendedReport) packetType() uint8 {
+	return TypeExtendedReport
+}
+
+// Block is an XR report block
+type Block interface {
+	blockType() uint8
+	// Marshal - encodes the block with its header
+	Marshal() []byte
+}
+
+// LossRLEBlock is a loss run length encoded report block (RFC 3611 section 4.1)
+type LossRLEBlock struct {
+	// SSRC: source the report is about
+	SSRC uint32
+	// Thinning: only every 2^Thinning sequence number is reported
+	Thinning uint8
+	// BeginSequence, EndSequence: reported sequence numbers, end excluded
+	BeginSequence uint16
+	EndSequence   uint16
+	// Chunks: run length and bit vector chunks, see Runs
+	Chunks []uint16
+}
+
+func (*LossRLEBlock) blockType() uint8 {
+	return BlockTypeLossRLE
+}
+
+// Runs calls run for each run of received or lost packets of the chunks, in sequence order
+func (b *LossRLEBlock) Runs(run func(received bool, length int)) {
+	for _, chunk := range b.Chunks {
+		switch {
+		case chunk == 0:
+			// null chunk, padding
+			return
+		case chunk&0x8000 == 0:
+			// run length chunk: run type and 14 bits run length
+			run(chunk&0x4000 != 0, int(chunk&0x3fff))
+		default:
+			// bit vector chunk: 15 packets, most significant bit first, 1 for received
+			for bit := 14; bit >= 0; bit-- {
+				run(chunk&(1<<uint(bit)) != 0, 1)
+			}
+		}
+	}
+}
+
+// ReceiverReferenceTimeBlock carries the NTP time of an XR sent by a receiver (RFC 3611 section 4.4)
+type ReceiverReferenceTimeBlock struct {
+	NTPTime uint64
+}
+
+func (*ReceiverReferenceTimeBlock) blockType() uint8 {
+	return BlockTypeReceiverReferenceTime
+}
+
+// DLRRReport is the reply of a sender to the receiver reference time of a receiver
+type DLRRReport struct {
+	// SSRC: receiver which sent the receiver reference time
+	SSRC uint32
+	// LastReceiverReport: middle 32 bits of the receiver reference time
+	LastReceiverReport uint32
+	// Delay: delay since the receiver reference time was received, in 1/65536 s
+	Delay uint32
+}
+
+// DLRRBlock is a delay since last receiver report block (RFC 3611 section 4.5)
+type DLRRBlock struct {
+	Reports []DLRRReport
+}
+
+func (*DLRRBlock) blockType() uint8 {
+	return BlockTypeDLRR
+}
+
+// VoIPMetricsBlock is a VoIP metrics report block (RFC 3611 section 4.7)
+type VoIPMetricsBlock struct {
+	// SSRC: source the report is about
+	SSRC uint32
+	// LossRate, DiscardRate: fraction of packets lost and discarded by the jitter buffer, in 1/256
+	LossRate    uint8
+	DiscardRate uint8
+	// BurstDensity, GapDensity: fraction of packets lost or discarded within bursts and gaps, in 1/256
+	BurstDensity uint8
+	GapDensity   uint8
+	// BurstDuration, GapDuration: mean duration of bursts and gaps (ms)
+	BurstDuration uint16
+	GapDuration   uint16
+	// RoundTripDelay, EndSystemDelay: (ms)
+	RoundTripDelay uint16
+	EndSystemDelay uint16
+	// SignalLevel, NoiseLevel: (dBm0, signed), RERL: residual echo return loss (dB), Gmin: gap threshold
+	SignalLevel int8
+	NoiseLevel  int8
+	RERL        uint8
+	Gmin        uint8
+	// RFactor, ExtRFactor: R-factor (0-100) and external R-factor, 127 when unavailable
+	RFactor    uint8
+	ExtRFactor uint8
+	// MOSLQ, MOSCQ: listening and conversational quality MOS in tenths (10-50), 127 when unavailable
+	MOSLQ uint8
+	MOSCQ uint8
+	// RXConfig: packet loss concealment and jitter buffer configuration
+	RXConfig uint8
+	// JBNominal, JBMaximum, JBAbsMax: nominal, maximum and absolute maximum jitter buffer delay (ms)
+	JBNominal uint16
+	JBMaximum uint16
+	JBAbsMax  uint16
+}
+
+func (*VoIPMetricsBlock) blockType() uint8 {
+	return BlockTypeVoIPMetrics
+}
+
+// DeJitterBufferBlock is a de-jitter buffer metrics block (RFC 7005)
+type DeJitterBufferBlock struct {
+	// Interval: I flag, 1 interval / 2 sampled / 3 cumulative duration
+	Interval uint8
+	// Adaptive: C flag, the jitter buffer is adaptive
+	Adaptive bool
+	// SSRC: source the report is about
+	SSRC uint32
+	// Nominal, Maximum, HighWaterMark, LowWaterMark: jitter buffer delays (ms),
+	// 0xfffe when over range and 0xffff when unavailable
+	Nominal       uint16
+	Maximum       uint16
+	HighWaterMark uint16
+	LowWaterMark  uint16
+}
+
+func (*DeJitterBufferBlock) blockType() uint8 {
+	return BlockTypeDeJitterBuffer
+}
+
+const (
+	// DeJitterBufferOverRange: value of a de-jitter buffer delay over range
+	DeJitterBufferOverRange = 0xfffe
+	// DeJitterBufferUnavailable: value of an unavailable de-jitter buffer delay
+	DeJitterBufferUnavailable = 0xffff
+)
+
+// Marshal encodes the extended report as an RTCP packet
+func (r *ExtendedReport) Marshal() []byte {
+	body := make([]byte, 4)
+	binary.BigEndian.PutUint32(body, r.SSRC)
+	for _, block := range r.Blocks {
+		body = append(body, block.Marshal()...)
+	}
+	return marshalPacket(TypeExtendedReport, 0, body)
+}
+
+// marshalBlock - prepends the XR block header to the body, a multiple of 4 bytes
+func marshalBlock(blockType uint8, typeSpecific uint8, body []byte) []byte {
+	data := make([]byte, 4, 4+len(body))
+	data[0] = blockType
+	data[1] = typeSpecific
+	binary.BigEndian.PutUint16(data[2:], uint16(len(body)/4))
+	return append(data, body...)
+}
+
+// Marshal encodes the block, chunks are padded with a null chunk to a multiple of 4 bytes
+func (b *LossRLEBlock) Marshal() []byte {
+	chunks := b.Chunks
+	if len(chunks)%2 != 0 {
+		chunks = append(chunks[:len(chunks):len(chunks)], 0)
+	}
+	body := make([]byte, 8+2*len(chunks))
+	binary.BigEndian.PutUint32(body, b.SSRC)
+	binary.BigEndian.PutUint16(body[4:], b.BeginSequence)
+	binary.BigEndian.PutUint16(body[6:], b.EndSequence)
+	for i, chunk := range chunks {
+		binary.BigEndian.PutUint16(body[8+2*i:], chunk)
+	}
+	return marshalBlock(BlockTypeLossRLE, b.Thinning&0x0f, body)
+}
+
+// Marshal encodes the block
+func (b *ReceiverReferenceTimeBlock) Marshal() []byte {
+	body := make([]byte, 8)
+	binary.BigEndian.PutUint64(body, b.NTPTime)
+	return marshalBlock(BlockTypeReceiverReferenceTime, 0, body)
+}
+
+// Marshal encodes the block
+func (b *DLRRBlock) Marshal() []byte {
+	body := make([]byte, 12*len(b.Reports))
+	for i, report := range b.Reports {
+		binary.BigEndian.PutUint32(body[12*i:], report.SSRC)
+		binary.BigEndian.PutUint32(body[12*i+4:], report.LastReceiverReport)
+		binary.BigEndian.PutUint32(body[12*i+8:], report.Delay)
+	}
+	return marshalBlock(BlockTypeDLRR, 0, body)
+}
+
+// Marshal encodes the block
+func (b *VoIPMetricsBlock) Marshal() []byte {
+	body := make([]byte, 32)
+	binary.BigEndian.PutUint32(body, b.SSRC)
+	body[4] = b.LossRate
+	body[5] = b.DiscardRate
+	body[6] = b.BurstDensity
+	body[7] = b.GapDensity
+	binary.BigEndian.PutUint16(body[8:], b.BurstDuration)
+	binary.BigEndian.PutUint16(body[10:], b.GapDuration)
+	binary.BigEndian.PutUint16(body[12:], b.RoundTripDelay)
+	binary.BigEndian.PutUint16(body[14:], b.EndSystemDelay)
+	body[16] = uint8(b.SignalLevel)
+	body[17] = uint8(b.NoiseLevel)
+	body[18] = b.RERL
+	body[19] = b.Gmin
+	body[20] = b.RFactor
+	body[21] = b.ExtRFactor
+	body[22] = b.MOSLQ
+	body[23] = b.MOSCQ
+	body[24] = b.RXConfig
+	binary.BigEndian.PutUint16(body[26:], b.JBNominal)
+	binary.BigEndian.PutUint16(body[28:], b.JBMaximum)
+	binary.BigEndian.PutUint16(body[30:], b.JBAbsMax)
+	return marshalBlock(BlockTypeVoIPMetrics, 0, body)
+}
+
+// Marshal encodes the block
+func (b *DeJitterBufferBlock) Marshal() []byte {
+	body := make([]byte, 12)
+	binary.BigEndian.PutUint32(body, b.SSRC)
+	binary.BigEndian.PutUint16(body[4:], b.Nominal)
+	binary.BigEndian.PutUint16(body[6:], b.Maximum)
+	binary.BigEndian.PutUint16(body[8:], b.HighWaterMark)
+	binary.BigEndian.PutUint16(body[10:], b.LowWaterMark)
+	typeSpecific := b.Interval << 6
+	if b.Adaptive {
+		typeSpecific |= 0x20
+	}
+	return marshalBlock(BlockTypeDeJitterBuffer, typeSpecific, body)
+}
+
+func unmarshalExtendedReport(body []byte) (*ExtendedReport, error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("%w: extended report too short", ErrInvalidPacket)
+	}
+	report := &ExtendedReport{SSRC: binary.BigEndian.Uint32(body)}
+
+	data := body[4:]
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("%w: %d trailing bytes in extended report", ErrInvalidPacket, len(data))
+		}
+		blockType := data[0]
+		length := (int(binary.BigEndian.Uint16(data[2:])) + 1) * 4
+		if length > len(data) {
+			return nil, fmt.Errorf("%w: XR block %d length %d exceeds %d bytes", ErrInvalidPacket, blockType, length, len(data))
+		}
+		block, err := unmarshalBlock(blockType, data[1], data[4:length])
+		if err != nil {
+			return nil, err
+		}
+		if block != nil {
+			report.Blocks = append(report.Blocks, block)
+		}
+		data = data[length:]
+	}
+	return report, nil
+}
+
+// unmarshalBlock - parses the contents of an XR block following its header, nil for unsupported block types
+func unmarshalBlock(blockType uint8, typeSpecific uint8, body []byte) (Block, error) {
+	short := func(minimum int) error {
+		if len(body) < minimum {
+			return fmt.Errorf("%w: XR block %d too short", ErrInvalidPacket, blockType)
+		}
+		return nil
+	}
+
+	switch blockType {
+	case BlockTypeLossRLE:
+		if err := short(8); err != nil {
+			return nil, err
+		}
+		block := &LossRLEBlock{
+			Thinning:      typeSpecific & 0x0f,
+			SSRC:          binary.BigEndian.Uint32(body),
+			BeginSequence: binary.BigEndian.Uint16(body[4:]),
+			EndSequence:   binary.BigEndian.Uint16(body[6:]),
+		}
+		for i := 8; i+2 <= len(body); i += 2 {
+			block.Chunks = append(block.Chunks, binary.BigEndian.Uint16(body[i:]))
+		}
+		return block, nil
+
+	case BlockTypeReceiverReferenceTime:
+		if err := short(8); err != nil {
+			return nil, err
+		}
+		return &ReceiverReferenceTimeBlock{NTPTime: binary.BigEndian.Uint64(body)}, nil
+
+	case BlockTypeDLRR:
+		block := &DLRRBlock{}
+		for i := 0; i+12 <= len(body); i += 12 {
+			block.Reports = append(block.Reports, DLRRReport{
+				SSRC:               binary.BigEndian.Uint32(body[i:]),
+				LastReceiverReport: binary.BigEndian.Uint32(body[i+4:]),
+				Delay:              binary.BigEndian.Uint32(body[i+8:]),
+			})
+		}
+		return block, nil
+
+	case BlockTypeVoIPMetrics:
+		if err := short(32); err != nil {
+			return nil, err
+		}
+		return &VoIPMetricsBlock{
+			SSRC:           binary.BigEndian.Uint32(body),
+			LossRate:       body[4],
+			DiscardRate:    body[5],
+			BurstDensity:   body[6],
+			GapDensity:     body[7],
+			BurstDuration:  binary.BigEndian.Uint16(body[8:]),
+			GapDuration:    binary.BigEndian.Uint16(body[10:]),
+			RoundTripDelay: binary.BigEndian.Uint16(body[12:]),
+			EndSystemDelay: binary.BigEndian.Uint16(body[14:]),
+			SignalLevel:    int8(body[16]),
+			NoiseLevel:     int8(body[17]),
+			RERL:           body[18],
+			Gmin:           body[19],
+			RFactor:        body[20],
+			ExtRFactor:     body[21],
+			MOSLQ:          body[22],
+			MOSCQ:          body[23],
+			RXConfig:       body[24],
+			JBNominal:      binary.BigEndian.Uint16(body[26:]),
+			JBMaximum:      binary.BigEndian.Uint16(body[28:]),
+			JBAbsMax:       binary.BigEndian.Uint16(body[30:]),
+		}, nil
+
+	case BlockTypeDeJitterBuffer:
+		if err := short(12); err != nil {
+			return nil, err
+		}
+		return &DeJitterBufferBlock{
+			Interval:      typeSpecific >> 6,
+			Adaptive:      typeSpecific&0x20 != 0,
+			SSRC:          binary.BigEndian.Uint32(body),
+			Nominal:       binary.BigEndian.Uint16(body[4:]),
+			Maximum:       binary.BigEndian.Uint16(body[6:]),
+			HighWaterMark: binary.BigEndian.Uint16(body[8:]),
+			LowWaterMark:  binary.BigEndian.Uint16(body[10:]),
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,228 @@
+package webrtcstats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Stats types of the report used for scoring (https://www.w3.org/TR/webrtc-stats/#rtcstatstype-str*)
+const (
+	TypeInboundRTP       = "inbound-rtp"
+	TypeOutboundRTP      = "outbound-rtp"
+	TypeRemoteInboundRTP = "remote-inbound-rtp"
+	TypeCodec            = "codec"
+	TypeCandidatePair    = "candidate-pair"
+	TypeTransport        = "transport"
+	TypeMediaSource      = "media-source"
+)
+
+// ErrInvalidReport is returned when the data is neither an array nor a map of stats objects
+var ErrInvalidReport = errors.New("report must be an array or an object of stats")
+
+// InboundRTPStreamStats contains the RTCInboundRtpStreamStats members used for scoring
+type InboundRTPStreamStats struct {
+	ID string `json:"id"`
+	// Timestamp: time of the measurement (ms)
+	Timestamp float64 `json:"timestamp"`
+	// Kind: audio or video, MediaType for older libwebrtc versions
+	Kind      string `json:"kind"`
+	MediaType string `json:"mediaType"`
+	SSRC      uint32 `json:"ssrc"`
+	// TrackIdentifier: id of the receiving MediaStreamTrack
+	TrackIdentifier string `json:"trackIdentifier"`
+	TransportID     string `json:"transportId"`
+	CodecID         string `json:"codecId"`
+
+	PacketsReceived  uint64 `json:"packetsReceived"`
+	PacketsLost      int64  `json:"packetsLost"`
+	PacketsDiscarded uint64 `json:"packetsDiscarded"`
+	BytesReceived    uint64 `json:"bytesReceived"`
+	// Jitter: interarrival jitter (s)
+	Jitter float64 `json:"jitter"`
+	// JitterBufferDelay: sum of the time spent in the jitter buffer by the emitted samples or frames (s)
+	JitterBufferDelay        float64 `json:"jitterBufferDelay"`
+	JitterBufferEmittedCount uint64  `json:"jitterBufferEmittedCount"`
+
+	FecPacketsReceived uint64 `json:"fecPacketsReceived"`
+	FramesDecoded      uint64 `json:"framesDecoded"`
+	FrameWidth         uint32 `json:"frameWidth"`
+	FrameHeight        uint32 `json:"frameHeight"`
+	FreezeCount        uint64 `json:"freezeCount"`
+	// TotalFreezesDuration, TotalPausesDuration: (s)
+	TotalFreezesDuration float64 `json:"totalFreezesDuration"`
+	TotalPausesDuration  float64 `json:"totalPausesDuration"`
+}
+
+// kind - audio or video
+func (s *InboundRTPStreamStats) kind() string {
+	if s.Kind != "" {
+		return s.Kind
+	}
+	return s.MediaType
+}
+
+// OutboundRTPStreamStats contains the RTCOutboundRtpStreamStats members used for scoring
+type OutboundRTPStreamStats struct {
+	ID string `json:"id"`
+	// Timestamp: time of the measurement (ms)
+	Timestamp float64 `json:"timestamp"`
+	// Kind: audio or video, MediaType for older libwebrtc versions
+	Kind      string `json:"kind"`
+	MediaType string `json:"mediaType"`
+	SSRC      uint32 `json:"ssrc"`
+	// Rid: simulcast layer, empty without simulcast
+	Rid           string `json:"rid"`
+	MediaSourceID string `json:"mediaSourceId"`
+	TransportID   string `json:"transportId"`
+	CodecID       string `json:"codecId"`
+
+	PacketsSent   uint64 `json:"packetsSent"`
+	BytesSent     uint64 `json:"bytesSent"`
+	FramesEncoded uint64 `json:"framesEncoded"`
+	FrameWidth    uint32 `json:"frameWidth"`
+	FrameHeight   uint32 `json:"frameHeight"`
+}
+
+// kind - audio or video
+func (s *OutboundRTPStreamStats) kind() string {
+	if s.Kind != "" {
+		return s.Kind
+	}
+	return s.MediaType
+}
+
+// RemoteInboundRTPStreamStats contains the RTCRemoteInboundRtpStreamStats members used for scoring,
+// as reported by the receiver of an outbound stream in RTCP receiver reports
+type RemoteInboundRTPStreamStats struct {
+	ID string `json:"id"`
+	// LocalID: id of the outbound-rtp stats of the stream
+	LocalID string `json:"localId"`
+	// FractionLost: fraction of the packets lost since the previous receiver report (0-1)
+	FractionLost float64 `json:"fractionLost"`
+	// Jitter, RoundTripTime: (s)
+	Jitter        float64 `json:"jitter"`
+	RoundTripTime float64 `json:"roundTripTime"`
+}
+
+// CodecStats contains the RTCCodecStats members used for scoring
+type CodecStats struct {
+	ID string `json:"id"`
+	// MimeType: e.g. audio/opus or video/VP8
+	MimeType    string `json:"mimeType"`
+	ClockRate   uint32 `json:"clockRate"`
+	SdpFmtpLine string `json:"sdpFmtpLine"`
+}
+
+// CandidatePairStats contains the RTCIceCandidatePairStats members used for scoring
+type CandidatePairStats struct {
+	ID          string `json:"id"`
+	TransportID string `json:"transportId"`
+	State       string `json:"state"`
+	Nominated   bool   `json:"nominated"`
+	// CurrentRoundTripTime: latest STUN round trip time (s)
+	CurrentRoundTripTime float64 `json:"currentRoundTripTime"`
+}
+
+// TransportStats contains the RTCTransportStats members used for scoring
+type TransportStats struct {
+	ID                      string `json:"id"`
+	SelectedCandidatePairID string `json:"selectedCandidatePairId"`
+}
+
+// MediaSourceStats contains the RTCMediaSourceStats members used for scoring
+type MediaSourceStats struct {
+	ID              string `json:"id"`
+	TrackIdentifier string `json:"trackIdentifier"`
+	// FramesPerSecond: frame rate of the video source
+	FramesPerSecond float64 `json:"framesPerSecond"`
+}
+
+// Report is a getStats() report, stats are indexed by id, stats of other types are ignored
+type Report struct {
+	InboundRTP       map[string]*InboundRTPStreamStats
+	OutboundRTP      map[string]*OutboundRTPStreamStats
+	RemoteInboundRTP map[string]*RemoteInboundRTPStreamStats
+	Codecs           map[string]*CodecStats
+	CandidatePairs   map[string]*CandidatePairStats
+	Transports       map[string]*TransportStats
+	MediaSources     map[string]*MediaSourceStats
+}
+
+// ParseReport parses a JSON encoded getStats() report, either an array of stats objects
+// (JSON.stringify([...report.values()])) or an object keyed by id (JSON.stringify(Object.fromEntries(report)))
+func ParseReport(data []byte) (*Report, error) {
+	var entries []json.RawMessage
+	switch trimmed := bytes.TrimSpace(data); {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, err
+		}
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var byID map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &byID); err != nil {
+			return nil, err
+		}
+		for _, entry := range byID {
+			entries = append(entries, entry)
+		}
+	default:
+		return nil, ErrInvalidReport
+	}
+
+	report := &Report{
+		InboundRTP:       map[string]*InboundRTPStreamStats{},
+		OutboundRTP:      map[string]*OutboundRTPStreamStats{},
+		RemoteInboundRTP: map[string]*RemoteInboundRTPStreamStats{},
+		Codecs:           map[string]*CodecStats{},
+		CandidatePairs:   map[string]*CandidatePairStats{},
+		Transports:       map[string]*TransportStats{},
+		MediaSources:     map[string]*MediaSourceStats{},
+	}
+	for _, entry := range entries {
+		var header struct {
+			ID   string `json:"id"`
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(entry, &header); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidReport, err)
+		}
+
+		var err error
+		switch header.Type {
+		case TypeInboundRTP:
+			stats := &InboundRTPStreamStats{}
+			err = json.Unmarshal(entry, stats)
+			report.InboundRTP[header.ID] = stats
+		case TypeOutboundRTP:
+			stats := &OutboundRTPStreamStats{}
+			err = json.Unmarshal(entry, stats)
+			report.OutboundRTP[header.ID] = stats
+		case TypeRemoteInboundRTP:
+			stats := &RemoteInboundRTPStreamStats{}
+			err = json.Unmarshal(entry, stats)
+			report.RemoteInboundRTP[header.ID] = stats
+		case TypeCodec:
+			stats := &CodecStats{}
+			err = json.Unmarshal(entry, stats)
+			report.Codecs[header.ID] = stats
+		case TypeCandidatePair:
+			stats := &CandidatePairStats{}
+			err = json.Unmarshal(entry, stats)
+			report.CandidatePairs[header.ID] = stats
+		case TypeTransport:
+			stats := &TransportStats{}
+			err = json.Unmarshal(entry, stats)
+			report.Transports[header.ID] = stats
+		case TypeMediaSource:
+			stats := &MediaSourceStats{}
+			err = json.Unmarshal(entry, stats)
+			report.MediaSources[header.ID] = stats
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", header.Type, header.ID, err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,246 @@
+package webrtcstats
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+// InboundStats returns the stats of the received streams between two snapshots, keyed by track identifier
+// (stats id when the track identifier is not reported).
+//
+// A stream needs to be in both snapshots. Counters of streams which restarted in between (counters going backwards
+// or a new SSRC) are counted from zero.
+func InboundStats(previous, current *Report) map[string]rtcmos.Stat {
+	stats := map[string]rtcmos.Stat{}
+	for id, cur := range current.InboundRTP {
+		prev, ok := previous.InboundRTP[id]
+		if !ok {
+			continue
+		}
+
+		accumulator := rtcmos.NewAccumulator(0)
+		if prev.SSRC == cur.SSRC {
+			accumulator.Add(inboundCounters(prev, previous))
+		} else {
+			accumulator.Add(rtcmos.Counters{Timestamp: prev.Timestamp})
+		}
+		if stat, ok := accumulator.Add(inboundCounters(cur, current)); ok {
+			key := cur.TrackIdentifier
+			if key == "" {
+				key = id
+			}
+			stats[key] = stat
+		}
+	}
+	return stats
+}
+
+// InboundCounters returns the cumulative counters of a received stream of the report, to be fed to an
+// rtcmos.Accumulator
+func InboundCounters(report *Report, id string) (rtcmos.Counters, bool) {
+	stats, ok := report.InboundRTP[id]
+	if !ok {
+		return rtcmos.Counters{}, false
+	}
+	return inboundCounters(stats, report), true
+}
+
+func inboundCounters(stats *InboundRTPStreamStats, report *Report) rtcmos.Counters {
+	counters := rtcmos.Counters{
+		Timestamp:                stats.Timestamp,
+		PacketsReceived:          stats.PacketsReceived,
+		PacketsLost:              stats.PacketsLost,
+		PacketsDiscarded:         stats.PacketsDiscarded,
+		BytesReceived:            stats.BytesReceived,
+		FramesDecoded:            stats.FramesDecoded,
+		FreezeCount:              stats.FreezeCount,
+		TotalFreezesDuration:     stats.TotalFreezesDuration * 1000,
+		TotalPausesDuration:      stats.TotalPausesDuration * 1000,
+		JitterBufferDelay:        stats.JitterBufferDelay * 1000,
+		JitterBufferEmittedCount: stats.JitterBufferEmittedCount,
+		Jitter:                   float32(stats.Jitter * 1000),
+		RoundTripTime:            roundTripTime(report, stats.TransportID),
+	}
+
+	codec := report.Codecs[stats.CodecID]
+	if stats.kind() == "video" {
+		counters.VideoConfig = &rtcmos.VideoConfig{}
+		if codec != nil {
+			counters.VideoConfig.Codec = codecName(codec.MimeType)
+		}
+		if stats.FrameWidth > 0 && stats.FrameHeight > 0 {
+			counters.VideoConfig.Width = int32Ptr(int32(stats.FrameWidth))
+			counters.VideoConfig.Height = int32Ptr(int32(stats.FrameHeight))
+		}
+		if stats.FecPacketsReceived > 0 {
+			counters.VideoConfig.Fec = boolPtr(true)
+		}
+	} else {
+		counters.AudioConfig = audioConfig(codec)
+	}
+	return counters
+}
+
+// OutboundStats returns the stats of the sent streams between two snapshots as experienced by the remote receiver,
+// keyed by track identifier of the media source, followed by /rid for simulcast layers (stats id when the media
+// source is not reported).
+//
+// Loss, jitter and round trip time come from the latest receiver report (remote-inbound-rtp), the candidate pair
+// round trip time is used when there is none. A stream needs to be in both snapshots.
+func OutboundStats(previous, current *Report) map[string]rtcmos.Stat {
+	remote := map[string]*RemoteInboundRTPStreamStats{}
+	for _, stats := range current.RemoteInboundRTP {
+		remote[stats.LocalID] = stats
+	}
+
+	stats := map[string]rtcmos.Stat{}
+	for id, cur := range current.OutboundRTP {
+		prev, ok := previous.OutboundRTP[id]
+		interval := 0.0
+		if ok {
+			interval = cur.Timestamp - prev.Timestamp
+		}
+		if interval <= 0 || prev.SSRC != cur.SSRC || cur.BytesSent < prev.BytesSent || cur.FramesEncoded < prev.FramesEncoded {
+			continue
+		}
+
+		stat := rtcmos.Stat{
+			Bitrate:       float32(float64(cur.BytesSent-prev.BytesSent) * 8 * 1000 / interval),
+			RoundTripTime: roundTripTime(current, cur.TransportID),
+			Interval:      int32Ptr(int32(math.Round(interval))),
+		}
+		if r, ok := remote[id]; ok {
+			stat.PacketLoss = float32(r.FractionLost * 100)
+			stat.Jitter = float32(r.Jitter * 1000)
+			if r.RoundTripTime > 0 {
+				stat.RoundTripTime = int32Ptr(int32(math.Round(r.RoundTripTime * 1000)))
+			}
+		}
+
+		codec := current.Codecs[cur.CodecID]
+		source := current.MediaSources[cur.MediaSourceID]
+		if cur.kind() == "video" {
+			stat.VideoConfig = &rtcmos.VideoConfig{
+				FrameRate: float32Ptr(float32(float64(cur.FramesEncoded-prev.FramesEncoded) * 1000 / interval)),
+			}
+			if codec != nil {
+				stat.VideoConfig.Codec = codecName(codec.MimeType)
+			}
+			if cur.FrameWidth > 0 && cur.FrameHeight > 0 {
+				stat.VideoConfig.Width = int32Ptr(int32(cur.FrameWidth))
+				stat.VideoConfig.Height = int32Ptr(int32(cur.FrameHeight))
+			}
+			// frames dropped by the encoder lower the frame rate compared to the capture
+			if source != nil && source.FramesPerSecond > 0 {
+				stat.VideoConfig.ExpectedFrameRate = float32Ptr(float32(source.FramesPerSecond))
+			}
+		} else {
+			stat.AudioConfig = audioConfig(codec)
+		}
+
+		key := id
+		if source != nil && source.TrackIdentifier != "" {
+			key = source.TrackIdentifier
+			if cur.Rid != "" {
+				key += "/" + cur.Rid
+			}
+		}
+		stats[key] = stat
+	}
+	return stats
+}
+
+// roundTripTime - current round trip time of the selected candidate pair of the transport (ms),
+// nil when not reported
+func roundTripTime(report *Report, transportID string) *int32 {
+	var pair *CandidatePairStats
+	if transport, ok := report.Transports[transportID]; ok {
+		pair = report.CandidatePairs[transport.SelectedCandidatePairID]
+	}
+	if pair == nil || pair.CurrentRoundTripTime <= 0 {
+		// without transport stats, use a nominated pair which succeeded, sorted by id to be deterministic
+		var ids []string
+		for id, candidate := range report.CandidatePairs {
+			if candidate.Nominated && candidate.State == "succeeded" && candidate.CurrentRoundTripTime > 0 &&
+				(candidate.TransportID == transportID || transportID == "") {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		sort.Strings(ids)
+		pair = report.CandidatePairs[ids[0]]
+	}
+	return int32Ptr(int32(math.Round(pair.CurrentRoundTripTime * 1000)))
+}
+
+// audioCodecs - codecs with impairment values by lower case mime subtype
+var audioCodecs = map[string]rtcmos.AudioCodec{
+	"opus":          rtcmos.AudioCodecOpus,
+	"multiopus":     rtcmos.AudioCodecOpus,
+	"pcmu":          rtcmos.AudioCodecPCMU,
+	"pcma":          rtcmos.AudioCodecPCMA,
+	"g722":          rtcmos.AudioCodecG722,
+	"ilbc":          rtcmos.AudioCodecILBC,
+	"amr-wb":        rtcmos.AudioCodecAMRWB,
+	"mp4a-latm":     rtcmos.AudioCodecAAC,
+	"mpeg4-generic": rtcmos.AudioCodecAAC,
+}
+
+// audioConfig - audio config of the codec, opus FEC and DTX are read from the fmtp line
+func audioConfig(codec *CodecStats) *rtcmos.AudioConfig {
+	audioConfig := &rtcmos.AudioConfig{}
+	if codec == nil {
+		return audioConfig
+	}
+
+	name := codecName(codec.MimeType)
+	if name == "red" {
+		// RED wraps opus
+		audioConfig.Red = boolPtr(true)
+	}
+	// codecs without impairment values are left empty and scored with the opus curve
+	audioConfig.Codec = audioCodecs[name]
+
+	if audioConfig.Codec == rtcmos.AudioCodecOpus && codec.SdpFmtpLine != "" {
+		parameters := fmtpParameters(codec.SdpFmtpLine)
+		audioConfig.Fec = boolPtr(parameters["useinbandfec"] == "1")
+		audioConfig.Dtx = boolPtr(parameters["usedtx"] == "1")
+	}
+	return audioConfig
+}
+
+// codecName - lower case subtype of the mime type, e.g. vp8 for video/VP8
+func codecName(mimeType string) string {
+	if i := strings.IndexByte(mimeType, '/'); i >= 0 {
+		mimeType = mimeType[i+1:]
+	}
+	return strings.ToLower(mimeType)
+}
+
+// fmtpParameters - parameters of an fmtp line, e.g. minptime=10;useinbandfec=1
+func fmtpParameters(line string) map[string]string {
+	parameters := map[string]string{}
+	for _, parameter := range strings.Split(line, ";") {
+		if kv := strings.SplitN(strings.TrimSpace(parameter), "=", 2); len(kv) == 2 {
+			parameters[strings.ToLower(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return parameters
+}
+
+func int32Ptr(x int32) *int32 {
+	return &x
+}
+
+func float32Ptr(x float32) *float32 {
+	return &x
+}
+
+func boolPtr(x bool) *bool {
+	return &x
+}
@@ -0,0 +1,219 @@
+package webrtcstats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/rtcscore-go/pkg/rtcmos"
+)
+
+// snapshots of a subscriber peer connection one second apart, as an array and as an object keyed by id
+const previousReport = `[
+	{"id": "T01", "type": "transport", "timestamp": 1000, "selectedCandidatePairId": "CPa"},
+	{"id": "CPa", "type": "candidate-pair", "timestamp": 1000, "transportId": "T01", "state": "succeeded", "nominated": true, "currentRoundTripTime": 0.08},
+	{"id": "CIT01_111", "type": "codec", "timestamp": 1000, "mimeType": "audio/opus", "clockRate": 48000, "sdpFmtpLine": "minptime=10;useinbandfec=1"},
+	{"id": "CIT01_96", "type": "codec", "timestamp": 1000, "mimeType": "video/VP9", "clockRate": 90000},
+	{"id": "ITa", "type": "inbound-rtp", "timestamp": 1000, "kind": "audio", "ssrc": 1111, "trackIdentifier": "audio-track",
+		"transportId": "T01", "codecId": "CIT01_111", "packetsReceived": 1000, "packetsLost": 10, "packetsDiscarded": 0,
+		"bytesReceived": 100000, "jitter": 0.01, "jitterBufferDelay": 3000, "jitterBufferEmittedCount": 48000},
+	{"id": "ITv", "type": "inbound-rtp", "timestamp": 1000, "kind": "video", "ssrc": 2222, "trackIdentifier": "video-track",
+		"transportId": "T01", "codecId": "CIT01_96", "packetsReceived": 5000, "packetsLost": 0, "bytesReceived": 2000000,
+		"jitter": 0.005, "jitterBufferDelay": 5, "jitterBufferEmittedCount": 100, "framesDecoded": 100,
+		"frameWidth": 1280, "frameHeight": 720, "freezeCount": 1, "totalFreezesDuration": 0.5, "totalPausesDuration": 0},
+	{"id": "ITgone", "type": "inbound-rtp", "timestamp": 1000, "kind": "audio", "ssrc": 3333, "packetsReceived": 10}
+]`
+
+const currentReport = `{
+	"T01": {"id": "T01", "type": "transport", "timestamp": 2000, "selectedCandidatePairId": "CPa"},
+	"CPa": {"id": "CPa", "type": "candidate-pair", "timestamp": 2000, "transportId": "T01", "state": "succeeded", "nominated": true, "currentRoundTripTime": 0.1},
+	"CIT01_111": {"id": "CIT01_111", "type": "codec", "timestamp": 2000, "mimeType": "audio/opus", "clockRate": 48000, "sdpFmtpLine": "minptime=10;useinbandfec=1"},
+	"CIT01_96": {"id": "CIT01_96", "type": "codec", "timestamp": 2000, "mimeType": "video/VP9", "clockRate": 90000},
+	"ITa": {"id": "ITa", "type": "inbound-rtp", "timestamp": 2000, "kind": "audio", "ssrc": 1111, "trackIdentifier": "audio-track",
+		"transportId": "T01", "codecId": "CIT01_111", "packetsReceived": 1045, "packetsLost": 15, "packetsDiscarded": 1,
+		"bytesReceived": 104000, "jitter": 0.02, "jitterBufferDelay": 6840, "jitterBufferEmittedCount": 96000},
+	"ITv": {"id": "ITv", "type": "inbound-rtp", "timestamp": 2000, "kind": "video", "ssrc": 2222, "trackIdentifier": "video-track",
+		"transportId": "T01", "codecId": "CIT01_96", "packetsReceived": 5200, "packetsLost": 0, "bytesReceived": 2250000,
+		"jitter": 0.006, "jitterBufferDelay": 6.5, "jitterBufferEmittedCount": 130, "framesDecoded": 130,
+		"frameWidth": 960, "frameHeight": 540, "freezeCount": 2, "totalFreezesDuration": 0.8, "totalPausesDuration": 0},
+	"ITgone": {"id": "ITgone", "type": "inbound-rtp", "timestamp": 2000, "kind": "audio", "ssrc": 4444, "packetsReceived": 5},
+	"Cunknown": {"id": "Cunknown", "type": "certificate", "timestamp": 2000, "fingerprint": "AA:BB"}
+}`
+
+func TestParseReport(t *testing.T) {
+	{
+		// array and object forms
+		previous, err := ParseReport([]byte(previousReport))
+		require.NoError(t, err)
+		require.Len(t, previous.InboundRTP, 3)
+		require.Equal(t, "video/VP9", previous.Codecs["CIT01_96"].MimeType)
+
+		current, err := ParseReport([]byte(currentReport))
+		require.NoError(t, err)
+		require.Len(t, current.InboundRTP, 3)
+		require.Equal(t, "CPa", current.Transports["T01"].SelectedCandidatePairID)
+	}
+	{
+		// invalid reports
+		_, err := ParseReport([]byte(`"stats"`))
+		require.ErrorIs(t, err, ErrInvalidReport)
+		_, err = ParseReport([]byte(`[1, 2]`))
+		require.ErrorIs(t, err, ErrInvalidReport)
+		_, err = ParseReport([]byte(`[{"id": "IT", "type": "inbound-rtp", "packetsReceived": "many"}]`))
+		require.Error(t, err)
+	}
+}
+
+func TestInboundStats(t *testing.T) {
+	previous, err := ParseReport([]byte(previousReport))
+	require.NoError(t, err)
+	current, err := ParseReport([]byte(currentReport))
+	require.NoError(t, err)
+
+	stats := InboundStats(previous, current)
+	require.Len(t, stats, 3)
+
+	{
+		// audio: 5 lost out of 50, 32 kbps, 80 ms in the jitter buffer per sample
+		audio := stats["audio-track"]
+		require.InDelta(t, 10, audio.PacketLoss, 1e-4)
+		require.InDelta(t, 32000, audio.Bitrate, 1e-3)
+		require.InDelta(t, 20, audio.Jitter, 1e-4)
+		require.InDelta(t, 100.0/45, audio.JitterBufferDiscard, 1e-4)
+		require.Equal(t, int32(80), *audio.BufferDelay)
+		require.Equal(t, int32(100), *audio.RoundTripTime)
+		require.Equal(t, int32(1000), *audio.Interval)
+		require.Equal(t, rtcmos.AudioCodecOpus, audio.AudioConfig.Codec)
+		require.True(t, *audio.AudioConfig.Fec)
+		require.False(t, *audio.AudioConfig.Dtx)
+		require.Nil(t, audio.VideoConfig)
+	}
+	{
+		// video: 2 Mbps at 30 fps, one more freeze of 300 ms
+		video := stats["video-track"]
+		require.Equal(t, float32(0), video.PacketLoss)
+		require.InDelta(t, 2000000, video.Bitrate, 1e-3)
+		require.Equal(t, int32(50), *video.BufferDelay)
+		require.Equal(t, "vp9", video.VideoConfig.Codec)
+		require.Equal(t, float32(30), *video.VideoConfig.FrameRate)
+		require.Equal(t, int32(960), *video.VideoConfig.Width)
+		require.Equal(t, int32(540), *video.VideoConfig.Height)
+		require.Equal(t, int32(1), *video.VideoConfig.FreezeCount)
+		require.Equal(t, int32(300), *video.VideoConfig.TotalFreezesDuration)
+		require.Nil(t, video.AudioConfig)
+	}
+	{
+		// ready to score
+		for _, result := range rtcmos.Evaluate([]rtcmos.Stat{stats["audio-track"], stats["video-track"]}) {
+			require.NoError(t, result.Err)
+		}
+	}
+	{
+		// the stream with a new SSRC restarted in between, its counters are counted from zero
+		restarted := stats["ITgone"]
+		require.Equal(t, float32(0), restarted.PacketLoss)
+		require.Equal(t, float32(0), restarted.Bitrate)
+		require.Equal(t, int32(1000), *restarted.Interval)
+	}
+	{
+		// counters of successive reports fed to an accumulator
+		accumulator := rtcmos.NewAccumulator(1000)
+		counters, ok := InboundCounters(previous, "ITv")
+		require.True(t, ok)
+		_, ok = accumulator.Add(counters)
+		require.False(t, ok)
+		counters, _ = InboundCounters(current, "ITv")
+		stat, ok := accumulator.Add(counters)
+		require.True(t, ok)
+		require.Equal(t, stats["video-track"], stat)
+
+		_, ok = InboundCounters(current, "unknown")
+		require.False(t, ok)
+	}
+	{
+		// the candidate pair is used without transport stats
+		delete(current.Transports, "T01")
+		require.Equal(t, int32(100), *InboundStats(previous, current)["audio-track"].RoundTripTime)
+		delete(current.CandidatePairs, "CPa")
+		require.Nil(t, InboundStats(previous, current)["audio-track"].RoundTripTime)
+	}
+}
+
+func TestOutboundStats(t *testing.T) {
+	previous, err := ParseReport([]byte(`[
+		{"id": "MS1", "type": "media-source", "timestamp": 0, "kind": "video", "trackIdentifier": "camera", "framesPerSecond": 30},
+		{"id": "CO_96", "type": "codec", "timestamp": 0, "mimeType": "video/VP8"},
+		{"id": "OTq", "type": "outbound-rtp", "timestamp": 0, "kind": "video", "ssrc": 1, "rid": "q", "mediaSourceId": "MS1",
+			"codecId": "CO_96", "bytesSent": 0, "framesEncoded": 0, "frameWidth": 320, "frameHeight": 180},
+		{"id": "OTa", "type": "outbound-rtp", "timestamp": 0, "kind": "audio", "ssrc": 2, "bytesSent": 0}
+	]`))
+	require.NoError(t, err)
+	current, err := ParseReport([]byte(`[
+		{"id": "MS1", "type": "media-source", "timestamp": 2000, "kind": "video", "trackIdentifier": "camera", "framesPerSecond": 30},
+		{"id": "CO_96", "type": "codec", "timestamp": 2000, "mimeType": "video/VP8"},
+		{"id": "OTq", "type": "outbound-rtp", "timestamp": 2000, "kind": "video", "ssrc": 1, "rid": "q", "mediaSourceId": "MS1",
+			"codecId": "CO_96", "bytesSent": 37500, "framesEncoded": 30, "frameWidth": 320, "frameHeight": 180},
+		{"id": "RIq", "type": "remote-inbound-rtp", "timestamp": 1900, "localId": "OTq", "fractionLost": 0.02, "jitter": 0.004, "roundTripTime": 0.12},
+		{"id": "OTa", "type": "outbound-rtp", "timestamp": 2000, "kind": "audio", "ssrc": 2, "bytesSent": 10000}
+	]`))
+	require.NoError(t, err)
+
+	stats := OutboundStats(previous, current)
+	require.Len(t, stats, 2)
+	{
+		// receiver report of the simulcast layer, frames dropped by the encoder
+		video := stats["camera/q"]
+		require.InDelta(t, 2, video.PacketLoss, 1e-4)
+		require.InDelta(t, 4, video.Jitter, 1e-4)
+		require.Equal(t, int32(120), *video.RoundTripTime)
+		require.InDelta(t, 150000, video.Bitrate, 1e-3)
+		require.Equal(t, float32(15), *video.VideoConfig.FrameRate)
+		require.Equal(t, float32(30), *video.VideoConfig.ExpectedFrameRate)
+		require.Equal(t, "vp8", video.VideoConfig.Codec)
+		require.Nil(t, video.BufferDelay)
+	}
+	{
+		// no receiver report nor candidate pair
+		audio := stats["OTa"]
+		require.Equal(t, float32(0), audio.PacketLoss)
+		require.Nil(t, audio.RoundTripTime)
+		require.NotNil(t, audio.AudioConfig)
+	}
+}
+
+func TestAudioConfig(t *testing.T) {
+	{
+		// opus without fec, with dtx
+		audioConfig := audioConfig(&CodecStats{MimeType: "audio/opus", SdpFmtpLine: "usedtx=1; useinbandfec=0"})
+		require.False(t, *audioConfig.Fec)
+		require.True(t, *audioConfig.Dtx)
+	}
+	{
+		// RED
+		audioConfig := audioConfig(&CodecStats{MimeType: "audio/red", SdpFmtpLine: "111/111"})
+		require.True(t, *audioConfig.Red)
+		require.Equal(t, rtcmos.AudioCodec(""), audioConfig.Codec)
+	}
+	{
+		// table codecs
+		require.Equal(t, rtcmos.AudioCodecPCMU, audioConfig(&CodecStats{MimeType: "audio/PCMU"}).Codec)
+		require.Equal(t, rtcmos.AudioCodecG722, audioConfig(&CodecStats{MimeType: "audio/G722"}).Codec)
+		require.Equal(t, rtcmos.AudioCodecAMRWB, audioConfig(&CodecStats{MimeType: "audio/AMR-WB"}).Codec)
+	}
+	{
+		// multiopus is opus
+		audioConfig := audioConfig(&CodecStats{MimeType: "audio/multiopus", SdpFmtpLine: "useinbandfec=1"})
+		require.Equal(t, rtcmos.AudioCodecOpus, audioConfig.Codec)
+		require.True(t, *audioConfig.Fec)
+	}
+	{
+		// codecs outside the table are left empty and can still be scored
+		for _, mimeType := range []string{"audio/ISAC", "audio/G729", "audio/L16"} {
+			stat := rtcmos.Stat{Bitrate: 32000, AudioConfig: audioConfig(&CodecStats{MimeType: mimeType})}
+			require.Equal(t, rtcmos.AudioCodec(""), stat.AudioConfig.Codec)
+			result := rtcmos.Evaluate([]rtcmos.Stat{stat})[0]
+			require.NoError(t, result.Err)
+			require.Greater(t, result.Scores.AudioScore, 1.0)
+		}
+	}
+}